@@ -36,6 +36,10 @@ func newAccountChain(address string, listener face.ChainListener, store store.Bl
 
 	self.listener = listener
 	self.snapshotPoint = stack.New()
+	// rebuild snapshot points from store
+	for _, point := range self.store.GetSnapshotPoints(self.address, 0) {
+		self.snapshotPoint.Push(point)
+	}
 	return self
 }
 
@@ -72,14 +76,18 @@ func (self *accountChain) GetBlockByHash(hash string) *common.AccountStateBlock
 func (self *accountChain) insertChain(block *common.AccountStateBlock) error {
 	defer monitor.LogTime("chain", "accountInsert", time.Now())
 	log.Info("insert to account Chain: %v", block)
-	self.store.PutAccount(self.address, block)
-	self.head = block
-	self.listener.AccountInsertCallback(self.address, block)
-	self.store.SetAccountHead(self.address, &common.HashHeight{Hash: block.Hash(), Height: block.Height()})
-
+	batch := self.store.NewBatch()
+	batch.PutAccount(self.address, block)
+	batch.SetAccountHead(self.address, &common.HashHeight{Hash: block.Hash(), Height: block.Height()})
 	if block.BlockType == common.RECEIVED {
-		self.store.PutSourceHash(block.SourceHash, block)
+		batch.PutSourceHash(block.SourceHash, block)
 	}
+	err := batch.Write()
+	if err != nil {
+		return err
+	}
+	self.head = block
+	self.listener.AccountInsertCallback(self.address, block)
 	return nil
 }
 func (self *accountChain) removeChain(block *common.AccountStateBlock) error {
@@ -89,18 +97,23 @@ func (self *accountChain) removeChain(block *common.AccountStateBlock) error {
 		return errors.New("has snapshot.")
 	}
 
-	head := self.store.GetAccountByHash(block.Hash())
-	self.store.DeleteAccount(self.address, common.HashHeight{Hash: block.Hash(), Height: block.Height()})
-	self.listener.AccountRemoveCallback(self.address, block)
-	self.head = head
+	head := self.store.GetAccountByHash(block.PreHash())
+	batch := self.store.NewBatch()
+	batch.DeleteAccount(self.address, common.HashHeight{Hash: block.Hash(), Height: block.Height()})
 	if head == nil {
-		self.store.SetAccountHead(self.address, nil)
+		batch.SetAccountHead(self.address, nil)
 	} else {
-		self.store.SetAccountHead(self.address, &common.HashHeight{Hash: head.Hash(), Height: head.Height()})
+		batch.SetAccountHead(self.address, &common.HashHeight{Hash: head.Hash(), Height: head.Height()})
 	}
 	if block.BlockType == common.RECEIVED {
-		self.store.DeleteSourceHash(block.SourceHash)
+		batch.DeleteSourceHash(block.SourceHash)
 	}
+	err := batch.Write()
+	if err != nil {
+		return err
+	}
+	self.head = head
+	self.listener.AccountRemoveCallback(self.address, block)
 	return nil
 }
 
@@ -150,65 +163,70 @@ func (self *accountChain) NextSnapshotPoint() (int, string) {
 	return -1, ""
 }
 
-func (self *accountChain) SnapshotPoint(snapshotHeight int, snapshotHash string, h *common.AccountHashH) error {
+// the first block above last snapshot point
+func (self *accountChain) oldestUnsnapshotted() *common.AccountStateBlock {
+	lastPoint := self.peek()
+	if lastPoint == nil {
+		return self.GetBlockByHeight(0)
+	}
+	return self.GetBlockByHeight(lastPoint.AccountHeight + 1)
+}
+
+// check the account hash height can be snapshot at snapshotHeight, nothing is changed.
+func (self *accountChain) checkSnapshotPoint(snapshotHeight int, snapshotHash string, h *common.AccountHashH) (*common.SnapshotPoint, error) {
 	// check valid
 	head := self.head
 	if head == nil {
-		return errors.New("account[" + self.address + "] not exist.")
+		return nil, errors.New("account[" + self.address + "] not exist.")
 	}
 
-	var lastPoint *common.SnapshotPoint
-	p := self.snapshotPoint.Peek()
-	if p != nil {
-		lastPoint = p.(*common.SnapshotPoint)
+	lastPoint := self.peek()
+	if lastPoint != nil {
 		if snapshotHeight <= lastPoint.SnapshotHeight ||
 			h.Height < lastPoint.AccountHeight {
 			errMsg := fmt.Sprintf("acount snapshot point check fail.sHeight:[%d], lastSHeight:[%d], aHeight:[%d], lastAHeight:[%d]",
 				snapshotHeight, lastPoint.SnapshotHeight, h.Height, lastPoint.AccountHeight)
-			return errors.New(errMsg)
+			return nil, errors.New(errMsg)
 		}
 	}
 
 	point := self.GetBlockByHeight(h.Height)
+	if point == nil {
+		return nil, errors.New("account[" + self.address + "] block not exist. accHeight: " + strconv.Itoa(h.Height))
+	}
 	if h.Hash == point.Hash() && h.Height == point.Height() {
-		point := &common.SnapshotPoint{SnapshotHeight: snapshotHeight, SnapshotHash: snapshotHash, AccountHash: h.Hash, AccountHeight: h.Height}
-		self.snapshotPoint.Push(point)
-		return nil
+		return &common.SnapshotPoint{SnapshotHeight: snapshotHeight, SnapshotHash: snapshotHash, AccountHash: h.Hash, AccountHeight: h.Height}, nil
 	} else {
 		errMsg := "account[" + self.address + "] state error. accHeight: " + strconv.Itoa(h.Height) +
 			"accHash:" + h.Hash +
 			" expAccHeight:" + strconv.Itoa(point.Height()) +
 			" expAccHash:" + point.Hash()
-		return errors.New(errMsg)
+		return nil, errors.New(errMsg)
 	}
 }
 
-//SnapshotPoint
-func (self *accountChain) RollbackSnapshotPoint(start *common.SnapshotPoint, end *common.SnapshotPoint) error {
+func (self *accountChain) getSnapshotPoints(fromSnapshotHeight int) []*common.SnapshotPoint {
+	return self.store.GetSnapshotPoints(self.address, fromSnapshotHeight)
+}
+
+func (self *accountChain) pushSnapshotPoint(point *common.SnapshotPoint) {
+	self.snapshotPoint.Push(point)
+}
+
+// point of a snapshot block is on top when the snapshot block is the head, it's removed together with the block
+func (self *accountChain) checkTopSnapshotPoint(snapshotHeight int, snapshotHash string) error {
 	point := self.peek()
-	if point == nil {
-		return errors.New("not exist snapshot point.")
-	}
-	if !point.Equals(start) {
-		return errors.New("not equals for start")
-	}
-	for {
-		point := self.peek()
-		if point == nil {
-			return errors.New("not exist snapshot point.")
-		}
-		if point.AccountHeight <= end.AccountHeight {
-			self.snapshotPoint.Pop()
-		} else {
-			break
-		}
-		if point.AccountHeight == end.AccountHeight {
-			break
-		}
+	if point == nil || point.SnapshotHeight != snapshotHeight || point.SnapshotHash != snapshotHash {
+		return errors.New("account[" + self.address + "] snapshot point of snapshot block[" +
+			strconv.Itoa(snapshotHeight) + "][" + snapshotHash + "] is not on top.")
 	}
 	return nil
 }
 
+func (self *accountChain) popSnapshotPoint() {
+	self.snapshotPoint.Pop()
+}
+
 //func (self *accountChain) rollbackSnapshotPoint(start *common.SnapshotPoint) error {
 //	point := self.peek()
 //	if point == nil {
@@ -1,9 +1,12 @@
 package chain
 
 import (
+	"errors"
+	"strconv"
 	"sync"
 
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/common/log"
 	"github.com/viteshan/naive-vite/store"
@@ -14,35 +17,80 @@ type BlockChain interface {
 	face.SnapshotWriter
 	face.AccountReader
 	face.AccountWriter
-	SetChainListener(listener face.ChainListener)
+	// listeners are called after blocks are inserted or removed
+	AddChainListener(listener face.ChainListener)
+	// snapshot blocks are finalized by producers of committee, nothing is final before it's set
+	SetCommittee(committee face.CommitteeReader)
+	// accounts which can be snapshotted by the next snapshot block upon head
+	SnapshotCandidates() (common.HashHeight, []*SnapshotCandidate, error)
+
+	// store evidence of double signing, return false if evidence of the slot exists
+	PutEvidence(e *common.Evidence) bool
+	GetEvidence(id string) *common.Evidence
+	ListEvidence() []*common.Evidence
+
+	// append reorg to journal, seq of it is set. only the last reorgJournalLen reorgs are kept
+	PutReorg(r *common.Reorg)
+	// reorgs order by seq
+	ListReorg() []*common.Reorg
+
+	Close() error
 }
 
+// reorgs kept in journal, older ones are pruned
+const reorgJournalLen = 1000
+
 type blockchain struct {
 	ac       sync.Map
 	sc       *snapshotChain
 	store    store.BlockStore
-	listener face.ChainListener
+	listener *chainListeners
+	finality *finality
 
-	mu sync.Mutex // account chain init
+	mu      sync.Mutex // account chain init
+	evMu    sync.Mutex
+	reorgMu sync.Mutex
 }
 
-func NewChain() BlockChain {
+// if dataDir is empty, blocks are only kept in memory
+func NewChain(dataDir string, genesisCfg *config.Genesis) BlockChain {
 	self := &blockchain{}
-	self.store = store.NewMemoryStore(GetGenesisSnapshot())
-	self.sc = newSnapshotChain(self.store)
-	self.listener = &defaultChainListener{}
+	if dataDir == "" {
+		self.store = store.NewMemoryStore()
+	} else {
+		s, err := store.NewDiskStore(dataDir)
+		if err != nil {
+			panic("open block store fail. dir:" + dataDir + ", err:" + err.Error())
+		}
+		self.store = s
+	}
+	self.sc = newSnapshotChain(self.store, newGenesis(genesisCfg))
+	self.listener = &chainListeners{}
+	self.finality = newFinality(self.sc.genesis)
+	if hashH := self.store.GetFinalized(); hashH != nil {
+		block := self.sc.GetBlockByHashH(*hashH)
+		if block == nil {
+			panic("finalized snapshot block[" + strconv.Itoa(hashH.Height) + "][" + hashH.Hash + "] not exist")
+		}
+		self.finality.set(block)
+	}
 	return self
 }
 func (self *blockchain) selfAc(addr string) *accountChain {
 	chain, ok := self.ac.Load(addr)
-	if !ok {
-		c := newAccountChain(addr, self.listener, self.store)
-		self.mu.Lock()
-		defer self.mu.Unlock()
-		self.ac.Store(addr, c)
-		chain, _ = self.ac.Load(addr)
+	if ok {
+		return chain.(*accountChain)
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	// created by another caller while waiting for mu
+	chain, ok = self.ac.Load(addr)
+	if ok {
+		return chain.(*accountChain)
 	}
-	return chain.(*accountChain)
+	c := newAccountChain(addr, self.listener, self.store)
+	self.ac.Store(addr, c)
+	return c
 }
 
 // query received block by send block
@@ -63,37 +111,103 @@ func (self *blockchain) GetAccountBySourceHash(address string, source string) *c
 }
 
 func (self *blockchain) NextAccountSnapshot() (common.HashHeight, []*common.AccountHashH, error) {
-	head := self.sc.head
-	//common.SnapshotBlock{}
+	hashH, candidates, err := self.SnapshotCandidates()
+	if err != nil {
+		return hashH, nil, err
+	}
 	var accounts []*common.AccountHashH
+	for _, c := range candidates {
+		accounts = append(accounts, c.Account)
+	}
+	return hashH, accounts, nil
+}
+
+func (self *blockchain) SnapshotCandidates() (common.HashHeight, []*SnapshotCandidate, error) {
+	head := self.sc.head
+	var candidates []*SnapshotCandidate
 	self.ac.Range(func(k, v interface{}) bool {
-		i, s := v.(*accountChain).NextSnapshotPoint()
+		c := v.(*accountChain)
+		i, s := c.NextSnapshotPoint()
 		if i < 0 {
 			return true
 		}
-		accounts = append(accounts, common.NewAccountHashH(k.(string), s, i))
+		oldest := c.oldestUnsnapshotted()
+		if oldest == nil {
+			log.Error("oldest block not exist. account:%s", k)
+			oldest = c.head
+		}
+		candidates = append(candidates, &SnapshotCandidate{Account: common.NewAccountHashH(k.(string), s, i), Oldest: oldest})
 		return true
 	})
-	if len(accounts) == 0 {
-		accounts = nil
-	}
-
-	return common.HashHeight{Hash: head.Hash(), Height: head.Height()}, accounts, nil
+	return common.HashHeight{Hash: head.Hash(), Height: head.Height()}, candidates, nil
 }
 
 func (self *blockchain) FindAccountAboveSnapshotHeight(address string, snapshotHeight int) *common.AccountStateBlock {
 	return self.selfAc(address).findAccountAboveSnapshotHeight(snapshotHeight)
 }
 
-func (self *blockchain) SetChainListener(listener face.ChainListener) {
+func (self *blockchain) AddChainListener(listener face.ChainListener) {
 	if listener == nil {
 		return
 	}
-	self.listener = listener
+	self.listener.add(listener)
+}
+
+func (self *blockchain) SetCommittee(committee face.CommitteeReader) {
+	self.finality.setCommittee(committee)
+	finalized := self.finality.next(self.sc.Head(), self)
+	if finalized != nil {
+		self.store.SetFinalized(&common.HashHeight{Hash: finalized.Hash(), Height: finalized.Height()})
+		self.finality.set(finalized)
+	}
+}
+
+func (self *blockchain) FinalizedSnapshot() *common.SnapshotBlock {
+	return self.finality.get()
+}
+
+func (self *blockchain) PutEvidence(e *common.Evidence) bool {
+	self.evMu.Lock()
+	defer self.evMu.Unlock()
+	if self.store.GetEvidence(e.Id()) != nil {
+		return false
+	}
+	self.store.PutEvidence(e)
+	return true
+}
+
+func (self *blockchain) GetEvidence(id string) *common.Evidence {
+	return self.store.GetEvidence(id)
+}
+
+func (self *blockchain) ListEvidence() []*common.Evidence {
+	return self.store.ListEvidence()
+}
+
+func (self *blockchain) PutReorg(r *common.Reorg) {
+	self.reorgMu.Lock()
+	defer self.reorgMu.Unlock()
+	r.Seq = self.store.GetReorgSeq() + 1
+	batch := self.store.NewBatch()
+	batch.PutReorg(r)
+	if r.Seq > reorgJournalLen {
+		batch.DeleteReorg(r.Seq - reorgJournalLen)
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("write reorg fail. seq:%d, err:%v", r.Seq, err)
+	}
+}
+
+func (self *blockchain) ListReorg() []*common.Reorg {
+	return self.store.ListReorg()
+}
+
+func (self *blockchain) Close() error {
+	return self.store.Close()
 }
 
 func (self *blockchain) GenesisSnapshot() (*common.SnapshotBlock, error) {
-	return GetGenesisSnapshot(), nil
+	return self.sc.genesis, nil
 }
 
 func (self *blockchain) HeadSnapshot() (*common.SnapshotBlock, error) {
@@ -113,22 +227,71 @@ func (self *blockchain) GetSnapshotByHeight(height int) *common.SnapshotBlock {
 }
 
 func (self *blockchain) InsertSnapshotBlock(block *common.SnapshotBlock) error {
-	err := self.sc.insertChain(block)
-	if err == nil {
-		// update next snapshot index
-		for _, account := range block.Accounts {
-			err := self.selfAc(account.Addr).SnapshotPoint(block.Height(), block.Hash(), account)
-			if err != nil {
-				log.Error("update snapshot point fail.")
-				return err
-			}
+	// check all snapshot points before writing anything
+	chains := make([]*accountChain, len(block.Accounts))
+	points := make([]*common.SnapshotPoint, len(block.Accounts))
+	for i, account := range block.Accounts {
+		ac := self.selfAc(account.Addr)
+		point, err := ac.checkSnapshotPoint(block.Height(), block.Hash(), account)
+		if err != nil {
+			log.Error("update snapshot point fail.")
+			return err
 		}
+		chains[i] = ac
+		points[i] = point
+	}
+
+	batch := self.store.NewBatch()
+	for i, ac := range chains {
+		batch.PutSnapshotPoint(ac.address, points[i])
+	}
+	// finalized block is stored with the block finalizing it
+	finalized := self.finality.next(block, self)
+	if finalized != nil {
+		batch.SetFinalized(&common.HashHeight{Hash: finalized.Hash(), Height: finalized.Height()})
 	}
-	return err
+	err := self.sc.insertChain(block, batch)
+	if err != nil {
+		return err
+	}
+	// update next snapshot index
+	for i, ac := range chains {
+		ac.pushSnapshotPoint(points[i])
+	}
+	if finalized != nil {
+		self.finality.set(finalized)
+	}
+	self.listener.SnapshotInsertCallback(block)
+	return nil
 }
 
 func (self *blockchain) RemoveSnapshotHead(block *common.SnapshotBlock) error {
-	return self.sc.removeChain(block)
+	finalized := self.finality.get()
+	if block.Height() <= finalized.Height() {
+		return errors.New("snapshot block[" + strconv.Itoa(block.Height()) + "] is finalized, finalized height: " +
+			strconv.Itoa(finalized.Height()))
+	}
+	// snapshot points of the block are deleted with it, accounts are unlocked only when the block is gone
+	chains := make([]*accountChain, len(block.Accounts))
+	batch := self.store.NewBatch()
+	for i, account := range block.Accounts {
+		ac := self.selfAc(account.Addr)
+		err := ac.checkTopSnapshotPoint(block.Height(), block.Hash())
+		if err != nil {
+			return err
+		}
+		batch.DeleteSnapshotPoint(ac.address, block.Height())
+		chains[i] = ac
+	}
+	err := self.sc.removeChain(block, batch)
+	if err != nil {
+		return err
+	}
+	for _, ac := range chains {
+		ac.popSnapshotPoint()
+	}
+	self.listener.SnapshotRemoveCallback(block)
+	return nil
 }
 
 func (self *blockchain) HeadAccount(address string) (*common.AccountStateBlock, error) {
@@ -154,24 +317,6 @@ func (self *blockchain) InsertAccountBlock(address string, block *common.Account
 func (self *blockchain) RemoveAccountHead(address string, block *common.AccountStateBlock) error {
 	return self.selfAc(address).removeChain(block)
 }
-func (self *blockchain) RollbackSnapshotPoint(address string, start *common.SnapshotPoint, end *common.SnapshotPoint) error {
-	return self.selfAc(address).RollbackSnapshotPoint(start, end)
-}
-
-type defaultChainListener struct {
-}
-
-func (*defaultChainListener) SnapshotInsertCallback(block *common.SnapshotBlock) {
-
-}
-
-func (*defaultChainListener) SnapshotRemoveCallback(block *common.SnapshotBlock) {
-
-}
-
-func (*defaultChainListener) AccountInsertCallback(address string, block *common.AccountStateBlock) {
-
-}
-
-func (*defaultChainListener) AccountRemoveCallback(address string, block *common.AccountStateBlock) {
+func (self *blockchain) GetSnapshotPoints(address string, fromSnapshotHeight int) []*common.SnapshotPoint {
+	return self.selfAc(address).getSnapshotPoints(fromSnapshotHeight)
 }
@@ -1,32 +1,427 @@
 package chain
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
-	"fmt"
-
 	"time"
 
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/tools"
 )
 
+// accounts of default genesis
+var viteshan = config.DevAddress(0).String()
+var jie = config.DevAddress(1).String()
+
 func TestGenesis(t *testing.T) {
+	cfg := config.DefaultGenesis()
+	g := newGenesis(cfg)
+	if g.snapshot.Hash() != tools.CalculateSnapshotHash(g.snapshot) {
+		t.Error("genesis snapshot hash error.", g.snapshot.Hash())
+	}
+	if len(g.snapshot.Accounts) != len(cfg.Accounts) {
+		t.Fatal("genesis snapshot accounts error.", len(g.snapshot.Accounts))
+	}
+	for i, a := range g.accounts {
+		if a.Hash() != tools.CalculateAccountHash(a) {
+			t.Error("genesis account hash error.", a.Signer(), a.Hash())
+		}
+		if g.snapshot.Accounts[i].Hash != a.Hash() || g.snapshot.Accounts[i].Addr != a.Signer() {
+			t.Error("genesis snapshot account error.", g.snapshot.Accounts[i])
+		}
+	}
+	if newGenesis(config.DefaultGenesis()).snapshot.Hash() != g.snapshot.Hash() {
+		t.Error("genesis is not deterministic.")
+	}
+}
+
+func TestGenesisFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "genesis.json")
+	spec := `{"NetId": 7, "Timestamp": 1540000000, "Producers": ["vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8"],
+		"Accounts": [{"Address": "vite_1cb2ab2738cd913654658e879bef8115eb1aa61a9be9d15c3a", "Balance": 1000},
+		{"Address": "vite_85e8adb768aed85f2445eb1d71b933370d2980916baa3c1f3c", "Balance": 5}]}`
+	err = ioutil.WriteFile(file, []byte(spec), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Node{GenesisFile: file}.Genesis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NetId != 7 {
+		t.Error("net id error.", cfg.NetId)
+	}
+
+	bc := NewChain("", cfg)
+	genesis, _ := bc.GenesisSnapshot()
+	if genesis.Timestamp().Unix() != 1540000000 {
+		t.Error("genesis time error.", genesis.Timestamp())
+	}
+	if genesis.Hash() == newGenesis(config.DefaultGenesis()).snapshot.Hash() {
+		t.Error("genesis should differ from default.")
+	}
+	for _, a := range cfg.Accounts {
+		head, _ := bc.HeadAccount(a.Address)
+		if head == nil || head.Amount != a.Balance {
+			t.Error("genesis account error.", a.Address, head)
+		}
+	}
+	head, _ := bc.HeadAccount(viteshan)
+	if head != nil {
+		t.Error("default genesis account should not exist.", head)
+	}
+}
+
+func TestGenesisCheck(t *testing.T) {
+	cfg := config.DefaultGenesis()
+	cfg.Accounts = append(cfg.Accounts, cfg.Accounts[0])
+	if cfg.Check() == nil {
+		t.Error("duplicated account should be rejected.")
+	}
+	cfg = config.DefaultGenesis()
+	cfg.Producers = nil
+	if cfg.Check() == nil {
+		t.Error("empty producers should be rejected.")
+	}
+	cfg = config.DefaultGenesis()
+	cfg.Accounts = append(cfg.Accounts, config.GenesisAccount{Address: "jie", Balance: 1})
+	if cfg.Check() == nil {
+		t.Error("invalid address should be rejected.")
+	}
+}
+
+func TestReopenChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bc := NewChain(dir, config.DefaultGenesis())
+	genesis, _ := bc.GenesisSnapshot()
+	block := common.NewSnapshotBlock(1, "", genesis.Hash(), viteshan, time.Unix(1533550880, 0), nil)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	bc = NewChain(dir, config.DefaultGenesis())
+	defer bc.Close()
+	head, _ := bc.HeadSnapshot()
+	if head.Hash() != block.Hash() || head.Height() != block.Height() {
+		t.Errorf("head not resumed. expect:%s, actual:%s", block.Hash(), head.Hash())
+	}
+	account, _ := bc.HeadAccount(jie)
+	if account == nil || account.Amount != 200 {
+		t.Errorf("genesis account not resumed. %v", account)
+	}
+}
+
+func TestSelfAcOnce(t *testing.T) {
+	bc := NewChain("", config.DefaultGenesis()).(*blockchain)
+	defer bc.Close()
+	chains := make([]*accountChain, 10)
+	var wg sync.WaitGroup
+	for i := range chains {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			chains[i] = bc.selfAc(jie)
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range chains {
+		if c != chains[0] {
+			t.Fatal("account chain should be created once.")
+		}
+	}
+}
+
+func TestReorgJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bc := NewChain(dir, config.DefaultGenesis())
+	for i := 0; i < 3; i++ {
+		bc.PutReorg(&common.Reorg{ForkPoint: common.HashHeight{Height: i}, Accounts: []*common.AccountHashH{common.NewAccountHashH(jie, "h", i)}})
+	}
+	bc.Close()
+
+	bc = NewChain(dir, config.DefaultGenesis())
+	defer bc.Close()
+	bc.PutReorg(&common.Reorg{ForkPoint: common.HashHeight{Height: 3}})
+	reorgs := bc.ListReorg()
+	if len(reorgs) != 4 {
+		t.Fatalf("four reorgs expected. %d", len(reorgs))
+	}
+	for i, r := range reorgs {
+		if r.Seq != i+1 || r.ForkPoint.Height != i {
+			t.Errorf("reorgs should be ordered by seq. %d, %v", i, r)
+		}
+	}
+	if len(reorgs[0].Accounts) != 1 || reorgs[0].Accounts[0].Addr != jie {
+		t.Errorf("accounts of reorg not resumed. %v", reorgs[0].Accounts)
+	}
+}
+
+func TestReorgJournalPrune(t *testing.T) {
+	bc := NewChain("", config.DefaultGenesis())
+	defer bc.Close()
+	for i := 0; i < reorgJournalLen+2; i++ {
+		bc.PutReorg(&common.Reorg{ForkPoint: common.HashHeight{Height: i}})
+	}
+	reorgs := bc.ListReorg()
+	if len(reorgs) != reorgJournalLen {
+		t.Fatalf("journal should be pruned. %d", len(reorgs))
+	}
+	if reorgs[0].Seq != 3 || reorgs[len(reorgs)-1].Seq != reorgJournalLen+2 {
+		t.Errorf("oldest reorgs should be pruned. first:%d, last:%d", reorgs[0].Seq, reorgs[len(reorgs)-1].Seq)
+	}
+}
+
+func TestInsertSnapshotFailWritesNothing(t *testing.T) {
+	bc := NewChain("", config.DefaultGenesis())
+	genesis, _ := bc.GenesisSnapshot()
+	accounts := []*common.AccountHashH{
+		common.NewAccountHashH(viteshan, genesis.Accounts[0].Hash, 0),
+		common.NewAccountHashH(jie, "wrong hash", 0),
+	}
+	block := common.NewSnapshotBlock(1, "", genesis.Hash(), viteshan, time.Unix(1533550880, 0), accounts)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err == nil {
+		t.Fatal("insert should fail.")
+	}
+	head, _ := bc.HeadSnapshot()
+	if head.Hash() != genesis.Hash() {
+		t.Errorf("snapshot head changed. %s", head.Hash())
+	}
+	if bc.GetSnapshotByHash(block.Hash()) != nil {
+		t.Error("snapshot block should not be stored.")
+	}
+	hashH, accs, _ := bc.NextAccountSnapshot()
+	if hashH.Hash != genesis.Hash() || len(accs) != 2 {
+		t.Errorf("snapshot point changed. %v", accs)
+	}
+}
+
+func TestSnapshotPointReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bc := NewChain(dir, config.DefaultGenesis())
+	genesis, _ := bc.GenesisSnapshot()
+	// snapshot genesis account of jie
+	_, accounts, _ := bc.NextAccountSnapshot()
+	if len(accounts) != 0 {
+		t.Fatalf("no account chain loaded, but got %d", len(accounts))
+	}
+	jieHead, _ := bc.HeadAccount(jie)
+	accounts = []*common.AccountHashH{common.NewAccountHashH(jie, jieHead.Hash(), jieHead.Height())}
+	block := common.NewSnapshotBlock(1, "", genesis.Hash(), viteshan, time.Unix(1533550880, 0), accounts)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	bc = NewChain(dir, config.DefaultGenesis())
+	points := bc.GetSnapshotPoints(jie, 0)
+	if len(points) != 1 || points[0].SnapshotHash != block.Hash() {
+		t.Fatalf("snapshot points not reloaded. %v", points)
+	}
+	if _, accounts, _ := bc.NextAccountSnapshot(); len(accounts) != 0 {
+		t.Errorf("confirmed account head should not be snapshot again. %v", accounts)
+	}
+	if err := bc.RemoveSnapshotHead(block); err != nil {
+		t.Fatal(err)
+	}
+	if points := bc.GetSnapshotPoints(jie, 0); len(points) != 0 {
+		t.Errorf("snapshot points not removed. %v", points)
+	}
+	if _, accounts, _ := bc.NextAccountSnapshot(); len(accounts) != 1 {
+		t.Errorf("account head should be unlocked. %v", accounts)
+	}
+	bc.Close()
+
+	// snapshot points are removed with the snapshot block
+	bc = NewChain(dir, config.DefaultGenesis())
+	defer bc.Close()
+	if points := bc.GetSnapshotPoints(jie, 0); len(points) != 0 {
+		t.Errorf("snapshot points not removed on disk. %v", points)
+	}
+}
+
+type fixedCommittee []common.Address
+
+func (self fixedCommittee) Members(t time.Time) ([]common.Address, error) {
+	return self, nil
+}
+
+func TestFinality(t *testing.T) {
+	bc := NewChain("", config.DefaultGenesis())
+	genesis, _ := bc.GenesisSnapshot()
+	insert := func(producer int) *common.SnapshotBlock {
+		head, _ := bc.HeadSnapshot()
+		block := common.NewSnapshotBlock(head.Height()+1, "", head.Hash(), config.DevAddress(producer).String(),
+			head.Timestamp().Add(time.Second), nil)
+		block.SetHash(tools.CalculateSnapshotHash(block))
+		if err := bc.InsertSnapshotBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
 
-	var genesisAccounts = []string{"viteshan", "jie"}
-	for _, a := range genesisAccounts {
-		genesis := common.NewAccountBlock(0, "", "", a, time.Unix(0, 0),
-			200, 0, 0, "", common.GENESIS, a, a, "", -1)
-		genesis.SetHash(tools.CalculateAccountHash(genesis))
+	// nothing is final without committee
+	first := insert(0)
+	if f := bc.FinalizedSnapshot(); f.Hash() != genesis.Hash() {
+		t.Fatalf("genesis should be finalized. %d", f.Height())
+	}
 
-		fmt.Println(a, &common.HashHeight{Hash: genesis.Hash(), Height: genesis.Height()})
+	// 4 of 5 producers are needed, repeated or unknown signers don't count
+	var committee fixedCommittee
+	for i := 0; i < 5; i++ {
+		committee = append(committee, config.DevAddress(i))
+	}
+	bc.SetCommittee(committee)
+	insert(1)
+	insert(2)
+	insert(2)
+	insert(5)
+	insert(3)
+	if f := bc.FinalizedSnapshot(); f.Height() != 0 {
+		t.Fatalf("block[%d] should not be finalized.", f.Height())
+	}
+	insert(4)
+	if f := bc.FinalizedSnapshot(); f.Hash() != first.Hash() {
+		t.Fatalf("block[%d] should be finalized, but %d.", first.Height(), f.Height())
 	}
 
-	var genesisAcc = []*common.AccountHashH{
-		{common.HashHeight{Hash: "71cef9aa8a67df1055a0801e2fc251fe14a298b8fc1098a54e27db31f7a75e20", Height: 0}, "viteshan"},
-		{common.HashHeight{Hash: "c3e7ab31e4834cf18df6705b96c655c845db44f4ee315c6fba5232b4f965fcf0", Height: 0}, "jie"},
+	// rollback stops at finalized block
+	for {
+		head, _ := bc.HeadSnapshot()
+		if head.Height() == first.Height() {
+			break
+		}
+		if err := bc.RemoveSnapshotHead(head); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bc.RemoveSnapshotHead(first); err == nil {
+		t.Error("finalized block should not be removed.")
 	}
+	if f := bc.FinalizedSnapshot(); f.Hash() != first.Hash() {
+		t.Error("finalized block should not go back.")
+	}
+}
 
-	var genesisSnapshot = common.NewSnapshotBlock(0, "b5a3ee58d163c283e5c8c0f65ff5b26a5cd64cb9dce8119ac4581ebcb54626fd", "", "viteshan", time.Unix(1533550878, 0), genesisAcc)
-	fmt.Println(tools.CalculateSnapshotHash(genesisSnapshot))
+func TestFinalityReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bc := NewChain(dir, config.DefaultGenesis())
+	var committee fixedCommittee
+	for i := 0; i < 5; i++ {
+		committee = append(committee, config.DevAddress(i))
+	}
+	bc.SetCommittee(committee)
+	var first *common.SnapshotBlock
+	for i := 0; i < 5; i++ {
+		head, _ := bc.HeadSnapshot()
+		block := common.NewSnapshotBlock(head.Height()+1, "", head.Hash(), config.DevAddress(i).String(),
+			head.Timestamp().Add(time.Second), nil)
+		block.SetHash(tools.CalculateSnapshotHash(block))
+		if err := bc.InsertSnapshotBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = block
+		}
+	}
+	if f := bc.FinalizedSnapshot(); f.Hash() != first.Hash() {
+		t.Fatalf("block[%d] should be finalized, but %d.", first.Height(), f.Height())
+	}
+	bc.Close()
+
+	// finalized block is restored before committee is set
+	bc = NewChain(dir, config.DefaultGenesis())
+	defer bc.Close()
+	if f := bc.FinalizedSnapshot(); f.Hash() != first.Hash() {
+		t.Fatalf("finalized block is not restored. %d", f.Height())
+	}
+	for {
+		head, _ := bc.HeadSnapshot()
+		if head.Height() == first.Height() {
+			break
+		}
+		if err := bc.RemoveSnapshotHead(head); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bc.RemoveSnapshotHead(first); err == nil {
+		t.Error("finalized block should not be removed after restart.")
+	}
+}
+
+func TestPackingPolicy(t *testing.T) {
+	bc := NewChain("", config.DefaultGenesis())
+	genesis, _ := bc.GenesisSnapshot()
+	bc.HeadAccount(viteshan)
+	bc.HeadAccount(jie)
+	_, candidates, _ := bc.SnapshotCandidates()
+	if len(candidates) != 2 {
+		t.Fatalf("genesis accounts should be candidates. %d", len(candidates))
+	}
+	for _, c := range candidates {
+		if c.Oldest == nil || c.Oldest.Height() != 0 {
+			t.Errorf("oldest block error. %v", c.Oldest)
+		}
+	}
+
+	// one account a block, the other one waits for the next block
+	policy := NewOldestFirstPolicy(1)
+	first := policy.Pack(candidates)
+	if len(first) != 1 {
+		t.Fatalf("packed accounts error. %d", len(first))
+	}
+	block := common.NewSnapshotBlock(1, "", genesis.Hash(), viteshan, time.Unix(1533550880, 0), first)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	_, candidates, _ = bc.SnapshotCandidates()
+	second := policy.Pack(candidates)
+	if len(second) != 1 || second[0].Addr == first[0].Addr {
+		t.Fatalf("the rest account should be packed next. %v", second)
+	}
+
+	// account waiting from an older snapshot goes first
+	older := common.NewAccountBlock(1, "", "", jie, time.Unix(1533550890, 0), 0, 0, 1, "", common.SEND, jie, viteshan, "", -1)
+	newer := common.NewAccountBlock(1, "", "", viteshan, time.Unix(1533550880, 0), 0, 0, 2, "", common.SEND, viteshan, jie, "", -1)
+	packed := policy.Pack([]*SnapshotCandidate{
+		{Account: common.NewAccountHashH(viteshan, "", 1), Oldest: newer},
+		{Account: common.NewAccountHashH(jie, "", 1), Oldest: older},
+	})
+	if len(packed) != 1 || packed[0].Addr != jie {
+		t.Errorf("older account should be packed first. %v", packed)
+	}
 }
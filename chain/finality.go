@@ -0,0 +1,85 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/face"
+	"github.com/viteshan/naive-vite/common/log"
+)
+
+// snapshot blocks walked back from head at most to find the final block
+const maxFinalityDepth = 1000
+
+// a snapshot block is irreversible once more than 2/3 of distinct producers of the committee
+// have produced blocks upon it. finalized block never goes back.
+type finality struct {
+	committee face.CommitteeReader
+	finalized *common.SnapshotBlock
+	mu        sync.RWMutex
+}
+
+func newFinality(genesis *common.SnapshotBlock) *finality {
+	return &finality{finalized: genesis}
+}
+
+func (self *finality) get() *common.SnapshotBlock {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.finalized
+}
+
+func (self *finality) setCommittee(committee face.CommitteeReader) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.committee = committee
+}
+
+// committee of head decides, signers of blocks above height h confirm block h.
+// nothing is finalized without committee. head may not be stored yet, blocks below it must be.
+// return the block finalized upon head, nil if finalized block does not change.
+func (self *finality) next(head *common.SnapshotBlock, reader face.SnapshotReader) *common.SnapshotBlock {
+	self.mu.RLock()
+	c, finalized := self.committee, self.finalized
+	self.mu.RUnlock()
+	if c == nil {
+		return nil
+	}
+	members, err := c.Members(head.Timestamp())
+	if err != nil {
+		log.Error("get committee fail. time:%s, err:%v", head.Timestamp(), err)
+		return nil
+	}
+	committee := make(map[string]bool)
+	for _, m := range members {
+		committee[m.String()] = true
+	}
+	need := len(committee)*2/3 + 1
+	signers := make(map[string]bool)
+	for h := head.Height(); h > finalized.Height() && head.Height()-h < maxFinalityDepth; h-- {
+		block := head
+		if h != head.Height() {
+			block = reader.GetSnapshotByHeight(h)
+		}
+		if block == nil {
+			return nil
+		}
+		if len(signers) >= need {
+			return block
+		}
+		if committee[block.Signer()] {
+			signers[block.Signer()] = true
+		}
+	}
+	return nil
+}
+
+func (self *finality) set(block *common.SnapshotBlock) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if block.Height() <= self.finalized.Height() {
+		return
+	}
+	log.Info("snapshot block finalized. height:%d, hash:%s.", block.Height(), block.Hash())
+	self.finalized = block
+}
@@ -0,0 +1,31 @@
+package chain
+
+import (
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+// genesis blocks built from genesis spec
+type genesis struct {
+	snapshot *common.SnapshotBlock
+	accounts []*common.AccountStateBlock
+}
+
+func newGenesis(cfg *config.Genesis) *genesis {
+	t := time.Unix(cfg.Timestamp, 0)
+	var accounts []*common.AccountStateBlock
+	var hashHs []*common.AccountHashH
+	for _, a := range cfg.Accounts {
+		block := common.NewAccountBlock(0, "", "", a.Address, t,
+			a.Balance, 0, 0, "", common.GENESIS, a.Address, a.Address, "", -1)
+		block.SetHash(tools.CalculateAccountHash(block))
+		accounts = append(accounts, block)
+		hashHs = append(hashHs, common.NewAccountHashH(a.Address, block.Hash(), block.Height()))
+	}
+	snapshot := common.NewSnapshotBlock(0, "", "", "", t, hashHs)
+	snapshot.SetHash(tools.CalculateSnapshotHash(snapshot))
+	return &genesis{snapshot: snapshot, accounts: accounts}
+}
@@ -0,0 +1,50 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/face"
+)
+
+// dispatch chain events to all listeners, account chains hold it before any listener is added
+type chainListeners struct {
+	listeners []face.ChainListener
+	rw        sync.RWMutex
+}
+
+func (self *chainListeners) add(listener face.ChainListener) {
+	self.rw.Lock()
+	defer self.rw.Unlock()
+	self.listeners = append(self.listeners, listener)
+}
+
+func (self *chainListeners) all() []face.ChainListener {
+	self.rw.RLock()
+	defer self.rw.RUnlock()
+	return self.listeners
+}
+
+func (self *chainListeners) SnapshotInsertCallback(block *common.SnapshotBlock) {
+	for _, l := range self.all() {
+		l.SnapshotInsertCallback(block)
+	}
+}
+
+func (self *chainListeners) SnapshotRemoveCallback(block *common.SnapshotBlock) {
+	for _, l := range self.all() {
+		l.SnapshotRemoveCallback(block)
+	}
+}
+
+func (self *chainListeners) AccountInsertCallback(address string, block *common.AccountStateBlock) {
+	for _, l := range self.all() {
+		l.AccountInsertCallback(address, block)
+	}
+}
+
+func (self *chainListeners) AccountRemoveCallback(address string, block *common.AccountStateBlock) {
+	for _, l := range self.all() {
+		l.AccountRemoveCallback(address, block)
+	}
+}
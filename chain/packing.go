@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"sort"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+// an account whose head moved since its last snapshot point
+type SnapshotCandidate struct {
+	Account *common.AccountHashH
+	// the oldest account block not snapshotted yet
+	Oldest *common.AccountStateBlock
+}
+
+// decides accounts of the next snapshot block, the rest wait for the blocks after it
+type PackingPolicy interface {
+	Pack(candidates []*SnapshotCandidate) []*common.AccountHashH
+}
+
+// accounts which wait longest are packed first, at most max accounts a block
+type oldestFirstPolicy struct {
+	max int
+}
+
+func NewOldestFirstPolicy(max int) PackingPolicy {
+	if max <= 0 {
+		panic("max accounts of snapshot block must be positive.")
+	}
+	return &oldestFirstPolicy{max: max}
+}
+
+func (self *oldestFirstPolicy) Pack(candidates []*SnapshotCandidate) []*common.AccountHashH {
+	sorted := make([]*SnapshotCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].Oldest, sorted[j].Oldest
+		if a.SnapshotHeight != b.SnapshotHeight {
+			return a.SnapshotHeight < b.SnapshotHeight
+		}
+		if !a.Timestamp().Equal(b.Timestamp()) {
+			return a.Timestamp().Before(b.Timestamp())
+		}
+		return sorted[i].Account.Addr < sorted[j].Account.Addr
+	})
+	if len(sorted) > self.max {
+		sorted = sorted[:self.max]
+	}
+	var result []*common.AccountHashH
+	for _, c := range sorted {
+		result = append(result, c.Account)
+	}
+	return result
+}
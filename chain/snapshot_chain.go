@@ -1,8 +1,6 @@
 package chain
 
 import (
-	"time"
-
 	"strconv"
 
 	"encoding/json"
@@ -14,37 +12,38 @@ import (
 
 // snapshot block chain
 type snapshotChain struct {
-	head  *common.SnapshotBlock
-	store store.BlockStore
-}
-
-func GetGenesisSnapshot() *common.SnapshotBlock {
-	return genesisSnapshot
+	head    *common.SnapshotBlock
+	genesis *common.SnapshotBlock
+	store   store.BlockStore
 }
 
-var genesisAccounts = []*common.AccountHashH{
-	{common.HashHeight{Hash: "71cef9aa8a67df1055a0801e2fc251fe14a298b8fc1098a54e27db31f7a75e20", Height: 0}, "viteshan"},
-	{common.HashHeight{Hash: "c3e7ab31e4834cf18df6705b96c655c845db44f4ee315c6fba5232b4f965fcf0", Height: 0}, "jie"},
-}
-
-var genesisSnapshot = common.NewSnapshotBlock(0, "b5a3ee58d163c283e5c8c0f65ff5b26a5cd64cb9dce8119ac4581ebcb54626fd", "", "viteshan", time.Unix(1533550878, 0), genesisAccounts)
-
-func newSnapshotChain(store store.BlockStore) *snapshotChain {
+func newSnapshotChain(store store.BlockStore, genesis *genesis) *snapshotChain {
 	chain := &snapshotChain{}
 	chain.store = store
+	chain.genesis = genesis.snapshot
 	// init genesis block
 	head := store.GetSnapshotHead()
 	if head != nil {
-		storeGenesis := store.GetSnapshotByHeight(genesisSnapshot.Height())
-		if storeGenesis.Hash() != genesisSnapshot.Hash() {
-			panic("error store snapshot hash. code:" + genesisSnapshot.Hash() + ", store:" + storeGenesis.Hash())
+		storeGenesis := store.GetSnapshotByHeight(chain.genesis.Height())
+		if storeGenesis.Hash() != chain.genesis.Hash() {
+			panic("error store snapshot hash. genesis:" + chain.genesis.Hash() + ", store:" + storeGenesis.Hash())
 		} else {
 			chain.head = chain.store.GetSnapshotByHeight(head.Height)
 		}
 	} else {
-		chain.head = genesisSnapshot
-		chain.store.PutSnapshot(genesisSnapshot)
-		chain.store.SetSnapshotHead(&common.HashHeight{Hash: genesisSnapshot.Hash(), Height: genesisSnapshot.Height()})
+		// genesis snapshot and genesis accounts are written together
+		batch := chain.store.NewBatch()
+		for _, a := range genesis.accounts {
+			batch.PutAccount(a.Signer(), a)
+			batch.SetAccountHead(a.Signer(), &common.HashHeight{Hash: a.Hash(), Height: a.Height()})
+		}
+		batch.PutSnapshot(chain.genesis)
+		batch.SetSnapshotHead(&common.HashHeight{Hash: chain.genesis.Hash(), Height: chain.genesis.Height()})
+		err := batch.Write()
+		if err != nil {
+			panic("write genesis snapshot fail. err:" + err.Error())
+		}
+		chain.head = chain.genesis
 	}
 	return chain
 }
@@ -84,24 +83,32 @@ func j(v interface{}) string {
 	return string(bytes)
 }
 
-func (self *snapshotChain) insertChain(block *common.SnapshotBlock) error {
+// all writes of block are committed with batch
+func (self *snapshotChain) insertChain(block *common.SnapshotBlock, batch store.Batch) error {
 	log.Info("insert to snapshot Chain: %s", j(block))
-	self.store.PutSnapshot(block)
+	batch.PutSnapshot(block)
+	batch.SetSnapshotHead(&common.HashHeight{Hash: block.Hash(), Height: block.Height()})
+	err := batch.Write()
+	if err != nil {
+		return err
+	}
 	self.head = block
-	self.store.SetSnapshotHead(&common.HashHeight{Hash: block.Hash(), Height: block.Height()})
 	return nil
 }
-func (self *snapshotChain) removeChain(block *common.SnapshotBlock) error {
+func (self *snapshotChain) removeChain(block *common.SnapshotBlock, batch store.Batch) error {
 	log.Info("remove from snapshot Chain: %s", block)
 
 	head := self.store.GetSnapshotByHash(block.PreHash())
-	self.store.DeleteSnapshot(common.HashHeight{Hash: block.Hash(), Height: block.Height()})
-	self.head = head
+	batch.DeleteSnapshot(common.HashHeight{Hash: block.Hash(), Height: block.Height()})
 	if head == nil {
-		self.store.SetSnapshotHead(nil)
+		batch.SetSnapshotHead(nil)
 	} else {
-		self.store.SetSnapshotHead(&common.HashHeight{Hash: head.Hash(), Height: head.Height()})
+		batch.SetSnapshotHead(&common.HashHeight{Hash: head.Hash(), Height: head.Height()})
 	}
-
+	err := batch.Write()
+	if err != nil {
+		return err
+	}
+	self.head = head
 	return nil
 }
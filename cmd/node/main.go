@@ -5,12 +5,20 @@ import (
 
 	"encoding/json"
 
+	"flag"
+	"io/ioutil"
+	"os/user"
+	"path/filepath"
+
 	"net"
 
 	"net/http"
 
+	"time"
+
 	"github.com/abiosoft/ishell"
 	"github.com/google/gops/agent"
+	"github.com/viteshan/naive-vite/common"
 	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/log"
 	"github.com/viteshan/naive-vite/consensus"
@@ -23,6 +31,8 @@ import (
 )
 
 func main() {
+	dataDir := flag.String("datadir", defaultDataDir(), "ledger and keystore of nodes are kept in datadir/{NodeId}, empty: memory only")
+	flag.Parse()
 	if err := agent.Listen(agent.Options{}); err != nil {
 		log.Fatal("%v", err)
 	}
@@ -124,6 +134,9 @@ func main() {
 				c.Print("BootAddr: ")
 				bootAddr := c.ReadLine()
 
+				c.Print("GenesisFile: ")
+				genesisFile := c.ReadLine()
+
 				addr, e := net.ResolveTCPAddr("tcp4", bootAddr)
 
 				if bootAddr == "" || e != nil {
@@ -133,7 +146,11 @@ func main() {
 					bootAddr = addr.String()
 				}
 
-				node = startNode(bootAddr, port, id)
+				nodeDir := ""
+				if *dataDir != "" {
+					nodeDir = filepath.Join(*dataDir, id)
+				}
+				node = startNode(bootAddr, port, id, genesisFile, nodeDir)
 				c.Println("node start for[" + bootAddr + "] successfully.")
 			},
 		})
@@ -210,6 +227,78 @@ func main() {
 				c.Println("miner stop successfully.")
 			},
 		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "add",
+			Help: "unlock address and mine for it.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
+				address := ""
+				if len(c.Args) == 1 {
+					address = c.Args[0]
+				} else {
+					c.Print("Address: ")
+					address = c.ReadLine()
+				}
+				if _, err := common.ParseAddress(address); err != nil {
+					c.Println("address is invalid.", err)
+					return
+				}
+				c.Print("Passphrase: ")
+				passphrase := c.ReadPassword()
+				err := node.Wallet().Unlock(address, passphrase, 0)
+				if err != nil {
+					c.Println("unlock address fail.", err)
+					return
+				}
+				err = node.AddCoinbase(address)
+				if err != nil {
+					c.Println("add coinBase fail.", err)
+					return
+				}
+				c.Println("add coinBase successfully.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "remove",
+			Help: "stop mining for address.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if len(c.Args) != 1 {
+					c.Println("address is required.")
+					return
+				}
+				err := node.RemoveCoinbase(c.Args[0])
+				if err != nil {
+					c.Println("remove coinBase fail.", err)
+					return
+				}
+				c.Println("remove coinBase successfully.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "list",
+			Help: "list coinBases of miner.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				for _, a := range node.Coinbases() {
+					c.Println(a)
+				}
+			},
+		})
 		shell.AddCmd(autoCmd)
 	}
 
@@ -218,43 +307,39 @@ func main() {
 			Name: "account",
 			Help: "start or stop node.",
 		}
-		//[list,create,balance,send,receive]
-		//autoCmd.AddCmd(&ishell.Cmd{
-		//	Name: "list",
-		//	Help: "list accounts.",
-		//	Func: func(c *ishell.Context) {
-		//		if node == nil {
-		//			c.Println("node should be started.")
-		//			return
-		//		}
-		//		node.Leger().ListRequest()
-		//		node.StartMiner()
-		//		c.Println("miner start successfully.")
-		//	},
-		//})
-
 		autoCmd.AddCmd(&ishell.Cmd{
 			Name: "set",
-			Help: "set address.",
+			Help: "set and unlock address.",
 			Func: func(c *ishell.Context) {
 				if node == nil {
 					c.Println("node should be started.")
 					return
 				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
 				address := ""
 				if len(c.Args) == 1 {
 					address = c.Args[0]
 				} else {
-					c.ShowPrompt(false)
-					defer c.ShowPrompt(true)
 					c.Print("Address: ")
 					address = c.ReadLine()
 				}
-				if address == "" {
-					c.Println("address is empty.")
+				if _, err := common.ParseAddress(address); err != nil {
+					c.Println("address is invalid.", err)
+					return
+				}
+				c.Print("Passphrase: ")
+				passphrase := c.ReadPassword()
+				err := node.Wallet().Unlock(address, passphrase, 0)
+				if err != nil {
+					c.Println("unlock address fail.", err)
+					return
+				}
+				err = node.Wallet().SetCoinBase(address)
+				if err != nil {
+					c.Println("set address fail.", err)
 					return
 				}
-				node.Wallet().SetCoinBase(address)
 				c.Println("set address successfully.")
 			},
 		})
@@ -269,15 +354,152 @@ func main() {
 				}
 				c.ShowPrompt(false)
 				defer c.ShowPrompt(true)
-				c.Print("Address: ")
-				address := c.ReadLine()
+				c.Print("Passphrase: ")
+				passphrase := c.ReadPassword()
+				c.Print("Repeat passphrase: ")
+				if c.ReadPassword() != passphrase {
+					c.Println("passphrase is not the same.")
+					return
+				}
+				address, err := node.Wallet().CreateAccount(passphrase)
+				if err != nil {
+					c.Println("create address fail.", err)
+					return
+				}
+				c.Println("create address[" + address + "] successfully.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "dev",
+			Help: "import well known key of default genesis, eg: account dev 0",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if len(c.Args) != 1 {
+					c.Println("index is required.")
+					return
+				}
+				index, err := strconv.Atoi(c.Args[0])
+				if err != nil || index < 0 {
+					c.Println("index is invalid.")
+					return
+				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
+				c.Print("Passphrase: ")
+				address, err := node.Wallet().ImportKey(config.DevKey(index), c.ReadPassword())
+				if err != nil {
+					c.Println("import address fail.", err)
+					return
+				}
+				c.Println("import address[" + address + "] successfully.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "mnemonic",
+			Help: "generate mnemonic seed of wallet and derive account 0.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
+				c.Print("Passphrase: ")
+				passphrase := c.ReadPassword()
+				c.Print("Repeat passphrase: ")
+				if c.ReadPassword() != passphrase {
+					c.Println("passphrase is not the same.")
+					return
+				}
+				mnemonic, err := node.Wallet().NewMnemonic(passphrase)
+				if err != nil {
+					c.Println("generate mnemonic fail.", err)
+					return
+				}
+				c.Println("mnemonic: " + mnemonic)
+				c.Println("please back up the mnemonic, all derived accounts can be recovered from it.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "derive",
+			Help: "derive account of index from mnemonic seed, eg: account derive 1",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if len(c.Args) != 1 {
+					c.Println("index is required.")
+					return
+				}
+				index, err := strconv.Atoi(c.Args[0])
+				if err != nil || index < 0 {
+					c.Println("index is invalid.")
+					return
+				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
+				c.Print("Passphrase: ")
+				address, err := node.Wallet().DeriveAccount(index, c.ReadPassword())
+				if err != nil {
+					c.Println("derive address fail.", err)
+					return
+				}
+				c.Println("derive address[" + address + "] successfully.")
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "recover",
+			Help: "recover wallet from mnemonic, eg: account recover 5",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				count := 1
+				if len(c.Args) == 1 {
+					i, err := strconv.Atoi(c.Args[0])
+					if err != nil || i < 1 {
+						c.Println("count is invalid.")
+						return
+					}
+					count = i
+				}
+				c.ShowPrompt(false)
+				defer c.ShowPrompt(true)
+				c.Print("Mnemonic: ")
+				mnemonic := c.ReadLine()
+				c.Print("Passphrase: ")
+				passphrase := c.ReadPassword()
+				addresses, err := node.Wallet().Recover(mnemonic, passphrase, count)
+				if err != nil {
+					c.Println("recover wallet fail.", err)
+					return
+				}
+				for _, a := range addresses {
+					c.Println("recover address[" + a + "] successfully.")
+				}
+			},
+		})
 
-				if address == "" {
-					c.Println("address is empty.")
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "list",
+			Help: "list accounts of wallet.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
 					return
 				}
-				node.Wallet().CreateAccount(address)
-				c.Println("create address successfully.")
+				for _, a := range node.Wallet().Accounts() {
+					c.Println(a)
+				}
 			},
 		})
 
@@ -316,8 +538,8 @@ func main() {
 				c.Print("to Address: ")
 				toAddress := c.ReadLine()
 
-				if toAddress == "" {
-					c.Println("to address is empty.")
+				if _, err := common.ParseAddress(toAddress); err != nil {
+					c.Println("to address is invalid.", err)
 					return
 				}
 				c.Print("to Amount: ")
@@ -331,6 +553,54 @@ func main() {
 				}
 			},
 		})
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "register",
+			Help: "register coinBase as producer candidate.",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if node.Wallet().CoinBase() == "" {
+					c.Println("please set coinBase.")
+					return
+				}
+				err := node.Leger().RegisterAccountBlock(node.Wallet().CoinBase())
+				if err != nil {
+					c.Println("register fail.", err)
+				} else {
+					c.Println("register success.")
+				}
+			},
+		})
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "vote",
+			Help: "vote for producer candidate by balance of coinBase, eg: account vote vite_xxx",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if node.Wallet().CoinBase() == "" {
+					c.Println("please set coinBase.")
+					return
+				}
+				if len(c.Args) != 1 {
+					c.Println("candidate is required.")
+					return
+				}
+				if _, err := common.ParseAddress(c.Args[0]); err != nil {
+					c.Println("candidate address is invalid.", err)
+					return
+				}
+				err := node.Leger().VoteAccountBlock(node.Wallet().CoinBase(), c.Args[0])
+				if err != nil {
+					c.Println("vote fail.", err)
+				} else {
+					c.Println("vote success.")
+				}
+			},
+		})
 		autoCmd.AddCmd(&ishell.Cmd{
 			Name: "receive",
 			Help: "receive tx.",
@@ -349,8 +619,8 @@ func main() {
 				c.Print("from Address: ")
 				fromAddress := c.ReadLine()
 
-				if fromAddress == "" {
-					c.Println("from address is empty.")
+				if _, err := common.ParseAddress(fromAddress); err != nil {
+					c.Println("from address is invalid.", err)
 					return
 				}
 				c.Print("from block hash: ")
@@ -499,6 +769,42 @@ func main() {
 			},
 		})
 
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "reorgs",
+			Help: "list the latest reorgs of snapshot chain, or detail of a reorg, eg: sblock reorgs [cnt], sblock reorgs detail [seq]",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				reorgs := node.Leger().Chain().ListReorg()
+				if len(c.Args) == 2 && c.Args[0] == "detail" {
+					seq, _ := strconv.Atoi(c.Args[1])
+					for _, r := range reorgs {
+						if r.Seq == seq {
+							bytes, _ := json.MarshalIndent(r, "", "  ")
+							c.Println(string(bytes))
+							return
+						}
+					}
+					c.Printf("reorg not exist. seq:%d\n", seq)
+					return
+				}
+				cnt := 10
+				if len(c.Args) == 1 {
+					cnt, _ = strconv.Atoi(c.Args[0])
+				}
+				if len(reorgs) > cnt {
+					reorgs = reorgs[len(reorgs)-cnt:]
+				}
+				c.Println("Seq\tTime\tForkHeight\tForkHash\tRemoved\tAdded\tAccounts")
+				for _, r := range reorgs {
+					c.Printf("%d\t%s\t%d\t%s\t%d\t%d\t%d\n", r.Seq, r.Time.Format("15:04:05"), r.ForkPoint.Height, r.ForkPoint.Hash,
+						len(r.Removed), len(r.Added), len(r.Accounts))
+				}
+			},
+		})
+
 		autoCmd.AddCmd(&ishell.Cmd{
 			Name: "detail",
 			Help: "detail for snapshot block.",
@@ -533,8 +839,7 @@ func main() {
 					c.Println("node should be started.")
 					return
 				}
-				info := node.Leger().Pool().Info("")
-				c.Println(info)
+				printPool(c, node, "")
 			},
 		})
 
@@ -547,15 +852,128 @@ func main() {
 					return
 				}
 				if len(c.Args) == 1 {
-					addr := c.Args[0]
-					info := node.Leger().Pool().Info(addr)
-					c.Println(info)
+					printPool(c, node, c.Args[0])
 				} else {
 					c.Println("aprint [addr]")
 				}
 			},
 		})
 
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "sdot",
+			Help: "print fork tree of snapshot pool in graphviz dot, or write it to file, eg: pool sdot snapshot.dot",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				file := ""
+				if len(c.Args) == 1 {
+					file = c.Args[0]
+				}
+				exportDot(c, node, "", file)
+			},
+		})
+
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "adot",
+			Help: "print fork tree of account pool in graphviz dot, or write it to file, eg: pool adot [addr] account.dot",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				if len(c.Args) == 1 {
+					exportDot(c, node, c.Args[0], "")
+				} else if len(c.Args) == 2 {
+					exportDot(c, node, c.Args[0], c.Args[1])
+				} else {
+					c.Println("adot [addr] [file]")
+				}
+			},
+		})
+
+		shell.AddCmd(autoCmd)
+	}
+
+	{
+		autoCmd := &ishell.Cmd{
+			Name: "consensus",
+			Help: "consensus info.",
+		}
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "stat",
+			Help: "print produced, late and missed blocks of producers in the last rounds, eg: consensus stat 10",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				rounds := 1
+				if len(c.Args) == 1 {
+					i, err := strconv.Atoi(c.Args[0])
+					if err != nil || i < 1 {
+						c.Println("rounds is invalid.")
+						return
+					}
+					rounds = i
+				}
+				stats := node.Consensus().Stat(rounds)
+				if len(stats) == 0 {
+					c.Println("no rounds tracked.")
+					return
+				}
+				c.Printf("rounds[%d-%d]", stats[0].Index, stats[len(stats)-1].Index)
+				c.Println()
+				c.Println("producer\tproduced\tlate\tmissed")
+				for _, p := range consensus.SumStat(stats) {
+					c.Printf("%s\t%d\t%d\t%d", p.Member, p.Produced, p.Late, p.Missed)
+					c.Println()
+				}
+			},
+		})
+		autoCmd.AddCmd(&ishell.Cmd{
+			Name: "schedule",
+			Help: "print producer slots in the next seconds, only slots of member if set, eg: consensus schedule 60 [member]",
+			Func: func(c *ishell.Context) {
+				if node == nil {
+					c.Println("node should be started.")
+					return
+				}
+				seconds := 60
+				if len(c.Args) >= 1 {
+					i, err := strconv.Atoi(c.Args[0])
+					if err != nil || i < 1 {
+						c.Println("seconds is invalid.")
+						return
+					}
+					seconds = i
+				}
+				member := ""
+				if len(c.Args) >= 2 {
+					if _, err := common.ParseAddress(c.Args[1]); err != nil {
+						c.Println("member address is invalid.", err)
+						return
+					}
+					member = c.Args[1]
+				}
+				from := time.Now()
+				slots, err := node.Consensus().Schedule(from, from.Add(time.Duration(seconds)*time.Second))
+				if err != nil {
+					c.Println("schedule fail.", err)
+					return
+				}
+				c.Println("time\tproducer\tfinal")
+				for _, s := range slots {
+					if member != "" && s.Member.String() != member {
+						continue
+					}
+					c.Printf("%s\t%s\t%t", s.STime.Format(time.RFC3339), s.Member, s.Final)
+					c.Println()
+				}
+			},
+		})
+
 		shell.AddCmd(autoCmd)
 	}
 
@@ -608,10 +1026,40 @@ func main() {
 	// run shell
 	shell.Run()
 }
-func startNode(bootAddr string, port int, nodeId string) node.Node {
+func printPool(c *ishell.Context, n node.Node, address string) {
+	report, err := n.PoolInfo(address)
+	if err != nil {
+		c.Printf("get pool info fail. err:%v\n", err)
+		return
+	}
+	bytes, _ := json.MarshalIndent(report, "", "  ")
+	c.Println(string(bytes))
+}
+
+func exportDot(c *ishell.Context, n node.Node, address string, file string) {
+	dot, err := n.PoolDot(address)
+	if err != nil {
+		c.Printf("export pool fail. err:%v\n", err)
+		return
+	}
+	if file == "" {
+		c.Println(dot)
+		return
+	}
+	err = ioutil.WriteFile(file, []byte(dot), 0644)
+	if err != nil {
+		c.Printf("write file fail. file:%s, err:%v\n", file, err)
+		return
+	}
+	c.Printf("fork tree is written to %s, render it by: dot -Tpng %s -o pool.png\n", file, file)
+}
+
+func startNode(bootAddr string, port int, nodeId string, genesisFile string, dataDir string) node.Node {
 	cfg := config.Node{
-		P2pCfg:       config.P2P{NodeId: nodeId, Port: port, LinkBootAddr: bootAddr, NetId: 0},
-		ConsensusCfg: config.Consensus{Interval: 1, MemCnt: len(consensus.DefaultMembers)},
+		P2pCfg:       config.P2P{NodeId: nodeId, Port: port, LinkBootAddr: bootAddr},
+		ConsensusCfg: config.Consensus{Interval: 1},
+		GenesisFile:  genesisFile,
+		DataDir:      dataDir,
 	}
 	n := node.NewNode(cfg)
 	n.Init()
@@ -619,6 +1067,15 @@ func startNode(bootAddr string, port int, nodeId string) node.Node {
 	return n
 }
 
+// ~/naive_vite/data, next to logs
+func defaultDataDir() string {
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(usr.HomeDir, "naive_vite", "data")
+}
+
 func checkArgs(args []string) (bool, string) {
 	if len(args) != 1 {
 		return false, ""
@@ -637,13 +1094,14 @@ func startBoot(bootAddr string) p2p.Boot {
 
 - boot[start,stop,list]
 - node[start,stop,peers]
-- miner[start,stop]
+- miner[start,stop,add,remove,list]
 
 
-- account[list,create,balance,send,receive]
+- account[set,create,dev,mnemonic,derive,recover,list,balance,send,receive,register,vote]
 - ablock[list,head,reqs,detail]
-- sblock[list,head,detail]
-- pool[sprint,aprint]
+- sblock[list,head,detail,reorgs]
+- pool[sprint,aprint,sdot,adot]
+- consensus[stat,schedule]
 - monitor[stat]
 - profile[start]
 */
@@ -1,14 +1,15 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/google/gops/agent"
 	"github.com/vitelabs/go-vite/consensus"
 	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/log"
+	"github.com/viteshan/naive-vite/monitor"
 	"github.com/viteshan/naive-vite/node"
 )
 
@@ -29,27 +30,32 @@ func main() {
 	n := startNode(defaultBoot, 8081, "100")
 	time.Sleep(time.Second)
 
-	balance := n.Leger().GetAccountBalance("jie")
+	// genesis accounts of default genesis
+	jie := unlockedAccount(n, config.DevKey(0))
+	viteshan := unlockedAccount(n, config.DevKey(1))
+
+	balance := n.Leger().GetAccountBalance(jie)
 	if balance != 200 {
 		return
 	}
 
-	balance = n.Leger().GetAccountBalance("viteshan")
+	balance = n.Leger().GetAccountBalance(viteshan)
 	if balance != 200 {
 		return
 	}
-	N := 4
+	// account chains of different addresses are inserted concurrently
+	N := 16
+	addrs := make([]string, N)
 	for i := 0; i < N; i++ {
-		addr := "jie" + strconv.Itoa(i)
-		err := n.Leger().RequestAccountBlock("jie", addr, -30)
+		addrs[i] = unlockedAccount(n, nil)
+		err := n.Leger().RequestAccountBlock(jie, addrs[i], -10)
 		if err != nil {
 			log.Error("%v", err)
 			return
 		}
 	}
 	for i := 0; i < N; i++ {
-		addr := "jie" + strconv.Itoa(i)
-		err := n.Leger().RequestAccountBlock("viteshan", addr, -30)
+		err := n.Leger().RequestAccountBlock(viteshan, addrs[i], -10)
 		if err != nil {
 			log.Error("%v", err)
 			return
@@ -57,11 +63,8 @@ func main() {
 	}
 
 	for i := 0; i < N; i++ {
-		from := "jie" + strconv.Itoa(i)
-		to := "jie" + strconv.Itoa(i-1)
-		if i == 0 {
-			to = "jie" + strconv.Itoa(N-1)
-		}
+		from := addrs[i]
+		to := addrs[(i+N-1)%N]
 		go func(from string, to string) {
 			for {
 				balance := n.Leger().GetAccountBalance(from)
@@ -99,15 +102,29 @@ func main() {
 				time.Sleep(time.Second)
 			}
 
-		}("jie" + strconv.Itoa(i))
+		}(addrs[i])
 	}
 
+	go logThroughput()
+
 	i := make(chan struct{})
 	<-i
 	//time.Sleep(30 * time.Second)
 	//boot.Stop()
 }
 
+// account blocks inserted per second, averaged over the last minute
+func logThroughput() {
+	for {
+		time.Sleep(10 * time.Second)
+		for _, s := range monitor.Stats() {
+			if s.Type == "chain" && s.Name == "accountInsert" {
+				log.Info("account blocks inserted per second: %.2f, insert time: %.2fms.", s.CntMean, s.Avg/1e6)
+			}
+		}
+	}
+}
+
 func startNode(bootAddr string, port int, nodeId string) node.Node {
 	cfg := config.Node{
 		P2pCfg:       config.P2P{NodeId: nodeId, Port: port, LinkBootAddr: bootAddr, NetId: 0},
@@ -118,3 +135,21 @@ func startNode(bootAddr string, port int, nodeId string) node.Node {
 	n.Start()
 	return n
 }
+
+// import key (create one if nil) and unlock it
+func unlockedAccount(n node.Node, key ed25519.PrivateKey) string {
+	var addr string
+	var err error
+	if key == nil {
+		addr, err = n.Wallet().CreateAccount("")
+	} else {
+		addr, err = n.Wallet().ImportKey(key, "")
+	}
+	if err == nil {
+		err = n.Wallet().Unlock(addr, "", 0)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
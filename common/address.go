@@ -0,0 +1,68 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	AddressPrefix       = "vite_"
+	AddressSize         = 20
+	addressChecksumSize = 5
+	hexAddressLength    = len(AddressPrefix) + 2*(AddressSize+addressChecksumSize)
+)
+
+// Address is the hash of a public key, shown as prefix + hex(hash) + hex(checksum)
+type Address [AddressSize]byte
+
+func PubKeyToAddress(pubKey []byte) Address {
+	var addr Address
+	copy(addr[:], blake2bHash(AddressSize, pubKey))
+	return addr
+}
+
+// parse and validate address string, the checksum must match
+func ParseAddress(s string) (Address, error) {
+	var addr Address
+	if len(s) != hexAddressLength {
+		return addr, errors.New("address[" + s + "] length error")
+	}
+	if !strings.HasPrefix(s, AddressPrefix) {
+		return addr, errors.New("address[" + s + "] prefix error")
+	}
+	b, err := hex.DecodeString(s[len(AddressPrefix):])
+	if err != nil {
+		return addr, errors.New("address[" + s + "] is not hex")
+	}
+	copy(addr[:], b[:AddressSize])
+	if !bytes.Equal(addr.checksum(), b[AddressSize:]) {
+		return addr, errors.New("address[" + s + "] checksum error")
+	}
+	return addr, nil
+}
+
+func IsValidAddress(s string) bool {
+	_, err := ParseAddress(s)
+	return err == nil
+}
+
+func (self Address) String() string {
+	return AddressPrefix + hex.EncodeToString(self[:]) + hex.EncodeToString(self.checksum())
+}
+
+func (self Address) checksum() []byte {
+	return blake2bHash(addressChecksumSize, self[:])
+}
+
+func blake2bHash(size int, data []byte) []byte {
+	h, err := blake2b.New(size, nil)
+	if err != nil {
+		panic(err)
+	}
+	h.Write(data)
+	return h.Sum(nil)
+}
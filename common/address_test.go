@@ -0,0 +1,50 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	// addresses of go-vite format
+	for _, s := range []string{
+		"vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8",
+		"vite_1cb2ab2738cd913654658e879bef8115eb1aa61a9be9d15c3a",
+	} {
+		addr, err := ParseAddress(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr.String() != s {
+			t.Error("address string error.", addr.String(), s)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"jie",
+		"vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf9", // checksum
+		"vite_3ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8", // typo
+		"vita_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8", // prefix
+		"vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15c",   // length
+		"vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cfz", // hex
+	} {
+		if IsValidAddress(s) {
+			t.Error("address should be invalid.", s)
+		}
+	}
+}
+
+func TestPubKeyToAddress(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	key := ed25519.NewKeyFromSeed(seed)
+	addr := PubKeyToAddress(key.Public().(ed25519.PublicKey))
+	parsed, err := ParseAddress(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != addr {
+		t.Error("parse address error.", parsed, addr)
+	}
+}
@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// time source of consensus and miner, tests replace it by a manual clock
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+func NewRealClock() Clock {
+	return &realClock{}
+}
+
+type realClock struct {
+}
+
+func (self *realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (self *realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (self *realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// time only moves by Add or Set
+type ManualClock struct {
+	now      time.Time
+	waiters  []*waiter
+	sleepers int
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+	sleep    bool // waiter of Sleep
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	c := &ManualClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (self *ManualClock) Now() time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.now
+}
+
+func (self *ManualClock) Sleep(d time.Duration) {
+	<-self.after(d, true)
+}
+
+func (self *ManualClock) After(d time.Duration) <-chan time.Time {
+	return self.after(d, false)
+}
+
+func (self *ManualClock) after(d time.Duration, sleep bool) <-chan time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	w := &waiter{deadline: self.now.Add(d), ch: make(chan time.Time, 1), sleep: sleep}
+	if d <= 0 {
+		w.ch <- self.now
+		return w.ch
+	}
+	self.waiters = append(self.waiters, w)
+	if sleep {
+		self.sleepers++
+		self.cond.Broadcast()
+	}
+	return w.ch
+}
+
+// move time forward and fire all waiters whose deadline has passed
+func (self *ManualClock) Add(d time.Duration) {
+	self.Set(self.Now().Add(d))
+}
+
+func (self *ManualClock) Set(t time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if t.Before(self.now) {
+		return
+	}
+	self.now = t
+	// fire by deadline order
+	sort.SliceStable(self.waiters, func(i, j int) bool {
+		return self.waiters[i].deadline.Before(self.waiters[j].deadline)
+	})
+	var rest []*waiter
+	for _, w := range self.waiters {
+		if w.deadline.After(t) {
+			rest = append(rest, w)
+		} else {
+			if w.sleep {
+				self.sleepers--
+			}
+			w.ch <- t
+		}
+	}
+	self.waiters = rest
+}
+
+// block until n goroutines are sleeping on clock, waiters of After are not counted
+func (self *ManualClock) BlockUntil(n int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for self.sleepers < n {
+		self.cond.Wait()
+	}
+}
@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1533550878, 0)
+	c := NewManualClock(start)
+
+	after := c.After(2 * time.Second)
+	done := make(chan time.Time)
+	go func() {
+		c.Sleep(time.Second)
+		done <- c.Now()
+	}()
+	c.BlockUntil(1)
+
+	c.Add(500 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("sleep should not finish before deadline.")
+	case <-after:
+		t.Fatal("after should not fire before deadline.")
+	default:
+	}
+
+	c.Add(500 * time.Millisecond)
+	if n := <-done; !n.Equal(start.Add(time.Second)) {
+		t.Error("sleep finish time error.", n)
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start.Add(time.Second)) {
+		t.Error("time should not go back.")
+	}
+	c.Set(start.Add(3 * time.Second))
+	if n := <-after; !n.Equal(start.Add(3 * time.Second)) {
+		t.Error("after fire time error.", n)
+	}
+
+	// non-positive duration doesn't wait
+	c.Sleep(0)
+	<-c.After(-time.Second)
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"strconv"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+// well known keys of default genesis for local test networks, never hold real value by them
+func DevKey(index int) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("naive-vite-dev-" + strconv.Itoa(index)))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func DevAddress(index int) common.Address {
+	return common.PubKeyToAddress(DevKey(index).Public().(ed25519.PublicKey))
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+type GenesisAccount struct {
+	Address string
+	Balance int
+}
+
+// genesis spec, all nodes of a network must use the same one
+type Genesis struct {
+	NetId     int
+	Timestamp int64 // unix seconds, also the start time of consensus
+	Producers []string
+	Accounts  []GenesisAccount
+}
+
+// producers of default genesis are DevAddress(0...4)
+var DefaultProducers = []string{
+	DevAddress(0).String(),
+	DevAddress(1).String(),
+	DevAddress(2).String(),
+	DevAddress(3).String(),
+	DevAddress(4).String(),
+}
+
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		NetId:     0,
+		Timestamp: 1533550878,
+		Producers: DefaultProducers,
+		Accounts: []GenesisAccount{
+			{Address: DevAddress(0).String(), Balance: 200},
+			{Address: DevAddress(1).String(), Balance: 200},
+		},
+	}
+}
+
+// load genesis spec from a json file
+func LoadGenesis(file string) (*Genesis, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	genesis := &Genesis{}
+	err = json.Unmarshal(bytes, genesis)
+	if err != nil {
+		return nil, err
+	}
+	err = genesis.Check()
+	if err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+func (self *Genesis) Check() error {
+	if len(self.Producers) == 0 {
+		return errors.New("genesis producers is empty")
+	}
+	for _, p := range self.Producers {
+		if _, err := common.ParseAddress(p); err != nil {
+			return err
+		}
+	}
+	accounts := make(map[string]bool)
+	for _, a := range self.Accounts {
+		if _, err := common.ParseAddress(a.Address); err != nil {
+			return err
+		}
+		if a.Balance < 0 {
+			return errors.New("genesis account[" + a.Address + "] balance is negative: " + strconv.Itoa(a.Balance))
+		}
+		if accounts[a.Address] {
+			return errors.New("genesis account[" + a.Address + "] is duplicated")
+		}
+		accounts[a.Address] = true
+	}
+	return nil
+}
@@ -1,13 +1,43 @@
 package config
 
-import "github.com/viteshan/naive-vite/common"
+import (
+	"errors"
+
+	"github.com/viteshan/naive-vite/common"
+)
 
 type Miner struct {
 	Enabled     bool
 	HexCoinbase string
+	// other producers mined by the same node
+	HexCoinbases []string
+	// slots are skipped while snapshot head is behind peers by more blocks, zero: default of miner
+	MaxBehind int
+}
+
+func (self Miner) CoinBase() (common.Address, error) {
+	return common.ParseAddress(self.HexCoinbase)
 }
 
-func (self Miner) CoinBase() common.Address {
-	coinbase := common.HexToAddress(self.HexCoinbase)
-	return coinbase
+// HexCoinbase and HexCoinbases without duplicates, empty ones are skipped
+func (self Miner) CoinBases() ([]common.Address, error) {
+	var result []common.Address
+	exists := make(map[string]bool)
+	for _, hex := range append([]string{self.HexCoinbase}, self.HexCoinbases...) {
+		if hex == "" {
+			continue
+		}
+		addr, err := common.ParseAddress(hex)
+		if err != nil {
+			return nil, err
+		}
+		if !exists[addr.String()] {
+			exists[addr.String()] = true
+			result = append(result, addr)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no coinbase is set")
+	}
+	return result, nil
 }
@@ -4,4 +4,14 @@ type Node struct {
 	P2pCfg       P2P
 	ConsensusCfg Consensus
 	MinerCfg     Miner
+	PoolCfg      Pool
+	DataDir      string // empty: memory store
+	GenesisFile  string // empty: default genesis
+}
+
+func (self Node) Genesis() (*Genesis, error) {
+	if self.GenesisFile == "" {
+		return DefaultGenesis(), nil
+	}
+	return LoadGenesis(self.GenesisFile)
 }
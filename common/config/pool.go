@@ -0,0 +1,39 @@
+package config
+
+// caps of orphan blocks in pool, which are not connected to any chain of pool yet,
+// and of blocks rolled back by a reorg. zero fields are filled by defaults.
+type Pool struct {
+	MaxBlocks        int // orphan blocks of snapshot pool
+	MaxAccountBlocks int // orphan blocks of every account pool
+	MaxSnippetLen    int // linked orphan blocks of a snippet
+	MaxDistance      int // blocks higher than chain head by more are dropped
+	MaxReorgDepth    int // forks rolling back more blocks of chain are refused
+	MaxAccountPools  int // account pools kept in memory, idle ones are evicted beyond it
+}
+
+func DefaultPool() Pool {
+	return Pool{MaxBlocks: 10000, MaxAccountBlocks: 1000, MaxSnippetLen: 500, MaxDistance: 2000, MaxReorgDepth: 100, MaxAccountPools: 10000}
+}
+
+func (self Pool) WithDefault() Pool {
+	def := DefaultPool()
+	if self.MaxBlocks <= 0 {
+		self.MaxBlocks = def.MaxBlocks
+	}
+	if self.MaxAccountBlocks <= 0 {
+		self.MaxAccountBlocks = def.MaxAccountBlocks
+	}
+	if self.MaxSnippetLen <= 0 {
+		self.MaxSnippetLen = def.MaxSnippetLen
+	}
+	if self.MaxDistance <= 0 {
+		self.MaxDistance = def.MaxDistance
+	}
+	if self.MaxReorgDepth <= 0 {
+		self.MaxReorgDepth = def.MaxReorgDepth
+	}
+	if self.MaxAccountPools <= 0 {
+		self.MaxAccountPools = def.MaxAccountPools
+	}
+	return self
+}
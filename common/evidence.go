@@ -0,0 +1,38 @@
+package common
+
+import (
+	"strconv"
+	"time"
+)
+
+// proof that a producer signed two different snapshot blocks for the same slot.
+// blocks are kept with accounts, hash of block can only be checked with them.
+type Evidence struct {
+	First  *SnapshotBlock
+	Second *SnapshotBlock
+}
+
+// blocks are ordered by hash, every node builds the same evidence from the same blocks
+func NewEvidence(a *SnapshotBlock, b *SnapshotBlock) *Evidence {
+	if a.Hash() > b.Hash() {
+		a, b = b, a
+	}
+	return &Evidence{First: a, Second: b}
+}
+
+// one evidence for a slot of signer
+func (self *Evidence) Id() string {
+	return EvidenceId(self.Signer(), self.Timestamp())
+}
+
+func (self *Evidence) Signer() string {
+	return self.First.Signer()
+}
+
+func (self *Evidence) Timestamp() time.Time {
+	return self.First.Timestamp()
+}
+
+func EvidenceId(signer string, timestamp time.Time) string {
+	return signer + "_" + strconv.FormatInt(timestamp.Unix(), 10)
+}
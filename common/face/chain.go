@@ -1,6 +1,10 @@
 package face
 
-import "github.com/viteshan/naive-vite/common"
+import (
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+)
 
 type ChainReader interface {
 	SnapshotReader
@@ -12,6 +16,8 @@ type SnapshotReader interface {
 	GetSnapshotByHashH(hashH common.HashHeight) *common.SnapshotBlock
 	GetSnapshotByHash(hash string) *common.SnapshotBlock
 	GetSnapshotByHeight(height int) *common.SnapshotBlock
+	// the highest irreversible snapshot block, genesis if no block is finalized
+	FinalizedSnapshot() *common.SnapshotBlock
 	//ListSnapshotBlock(limit int) []*common.SnapshotBlock
 }
 type AccountReader interface {
@@ -24,6 +30,8 @@ type AccountReader interface {
 	GetAccountBySourceHash(address string, source string) *common.AccountStateBlock
 	NextAccountSnapshot() (common.HashHeight, []*common.AccountHashH, error)
 	FindAccountAboveSnapshotHeight(address string, snapshotHeight int) *common.AccountStateBlock
+	// snapshot points whose snapshot height >= fromSnapshotHeight, order by snapshot height
+	GetSnapshotPoints(address string, fromSnapshotHeight int) []*common.SnapshotPoint
 }
 
 type SnapshotWriter interface {
@@ -33,7 +41,6 @@ type SnapshotWriter interface {
 type AccountWriter interface {
 	InsertAccountBlock(address string, block *common.AccountStateBlock) error
 	RemoveAccountHead(address string, block *common.AccountStateBlock) error
-	RollbackSnapshotPoint(address string, start *common.SnapshotPoint, end *common.SnapshotPoint) error
 }
 
 type ChainListener interface {
@@ -43,6 +50,13 @@ type ChainListener interface {
 	AccountRemoveCallback(address string, block *common.AccountStateBlock)
 }
 
+// producers of the consensus group at time t
+type CommitteeReader interface {
+	Members(t time.Time) ([]common.Address, error)
+}
+
 type SyncStatus interface {
 	Done() bool
+	// snapshot blocks which local head is behind the best peer, 0 if not behind
+	Behind() int
 }
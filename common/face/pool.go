@@ -8,6 +8,9 @@ type PoolWriter interface {
 
 	AddAccountBlock(address string, block *common.AccountStateBlock) error
 	AddDirectAccountBlock(address string, block *common.AccountStateBlock) error
+
+	// evidence of double signing, from network or found by pool
+	AddEvidence(e *common.Evidence) error
 }
 
 type PoolReader interface {
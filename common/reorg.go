@@ -0,0 +1,16 @@
+package common
+
+import "time"
+
+// journal record of a reorg of snapshot chain
+type Reorg struct {
+	Seq       int // set by chain, increases by one with every reorg
+	Time      time.Time
+	ForkPoint HashHeight
+	// snapshot blocks rolled back, from old head down to fork point
+	Removed []HashHeight
+	// snapshot blocks of the new chain, from fork point up
+	Added []HashHeight
+	// account chains rolled back with the snapshot blocks, new heads of them
+	Accounts []*AccountHashH
+}
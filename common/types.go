@@ -11,8 +11,14 @@ type Block interface {
 	PreHash() string
 	Signer() string
 	Timestamp() time.Time
+	Signature() []byte
+	PubKey() []byte
 }
 
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(a Address, data []byte) (signedData, pubkey []byte, err error)
+
 type HashHeight struct {
 	Hash   string
 	Height int
@@ -84,6 +90,7 @@ func init() {
 		SnapshotHashes:        "SnapshotHashes",
 		AccountBlocks:         "AccountBlocks",
 		SnapshotBlocks:        "SnapshotBlocks",
+		Evidences:             "Evidences",
 	}
 }
 
@@ -107,6 +114,8 @@ type Tblock struct {
 	TpreHash   string
 	Tsigner    string
 	Ttimestamp time.Time
+	Tsignature []byte // ed25519 signature of Thash
+	TpubKey    []byte
 }
 
 func (self *Tblock) Height() int {
@@ -127,9 +136,19 @@ func (self *Tblock) Signer() string {
 func (self *Tblock) Timestamp() time.Time {
 	return self.Ttimestamp
 }
+func (self *Tblock) Signature() []byte {
+	return self.Tsignature
+}
+func (self *Tblock) PubKey() []byte {
+	return self.TpubKey
+}
 func (self *Tblock) SetHash(hash string) {
 	self.Thash = hash
 }
+func (self *Tblock) SetSignature(signature []byte, pubKey []byte) {
+	self.Tsignature = signature
+	self.TpubKey = pubKey
+}
 
 type AccountStateBlock struct {
 	Tblock
@@ -142,8 +161,12 @@ type AccountStateBlock struct {
 	To             string
 	SourceHash     string // source Block Thash
 	SourceHeight   int
+	Data           string // extra data of send block, eg: address of voted candidate
 }
 
+// account entries of a snapshot block at most
+const MaxSnapshotAccounts = 1000
+
 type SnapshotBlock struct {
 	Tblock
 	Accounts []*AccountHashH
@@ -168,16 +191,6 @@ func NewSnapshotBlock(
 	return block
 }
 
-type Address []byte
-
-func HexToAddress(hexStr string) Address {
-	return []byte(hexStr)
-}
-
-func (self Address) String() string {
-	return string((self)[:])
-}
-
 func NewAccountBlock(
 	height int,
 	hash string,
@@ -265,4 +278,5 @@ const (
 	SnapshotHashes        NetMsgType = 122
 	AccountBlocks         NetMsgType = 123
 	SnapshotBlocks        NetMsgType = 124
+	Evidences             NetMsgType = 125
 )
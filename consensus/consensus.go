@@ -1,10 +1,15 @@
 package consensus
 
 import (
+	"time"
+
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/face"
 )
 
+// snapshot chain which the verified block is appended to
 type SnapshotReader interface {
+	face.SnapshotReader
 }
 
 type SnapshotHeader struct {
@@ -13,6 +18,7 @@ type SnapshotHeader struct {
 }
 
 type ConsensusVerifier interface {
+	// check the signer of block owns the time slot of block, error explains the reason if not
 	Verify(reader SnapshotReader, block *common.SnapshotBlock) (bool, error)
 }
 
@@ -31,8 +37,18 @@ type AccountsConsensus interface {
 type Consensus interface {
 	ConsensusVerifier
 	Seal
-
+	// track produced and missed blocks by snapshot chain events
+	face.ChainListener
+	// stats of the last rounds, current round included
+	Stat(rounds int) []*RoundStat
+	// committee which finalizes snapshot blocks
+	face.CommitteeReader
+	// slots starting in [from, to), order by time
+	Schedule(from time.Time, to time.Time) ([]*Slot, error)
+
+	// slots of every subscribed member are notified
 	Subscribe(subscribeMem *SubscribeMem)
+	UnSubscribe(mem common.Address)
 	Init()
 	Start()
 	Stop()
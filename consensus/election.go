@@ -0,0 +1,165 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/face"
+)
+
+// send block to RegisterAddress registers the sender as a producer candidate.
+// send block to VoteAddress votes for the candidate in Data, weighted by the voter's balance.
+// a later vote of the same voter replaces the former one.
+// nobody owns the keys of these addresses, so they never produce blocks.
+var RegisterAddress = common.Address{19: 1}
+var VoteAddress = common.Address{19: 2}
+
+// registrations and votes recorded by snapshot chain, up to a snapshot block
+type voteTally struct {
+	hashH      common.HashHeight
+	heads      map[string]int // snapshotted account height
+	balances   map[string]int
+	candidates map[string]bool
+	votes      map[string]string // voter -> candidate
+}
+
+func newVoteTally() *voteTally {
+	return &voteTally{
+		hashH:      common.HashHeight{Height: -1},
+		heads:      make(map[string]int),
+		balances:   make(map[string]int),
+		candidates: make(map[string]bool),
+		votes:      make(map[string]string),
+	}
+}
+
+// apply account blocks snapshotted by block, block must be the next of hashH
+func (self *voteTally) apply(reader face.ChainReader, block *common.SnapshotBlock) error {
+	for _, a := range block.Accounts {
+		from, ok := self.heads[a.Addr]
+		if !ok {
+			from = -1
+		}
+		for h := from + 1; h <= a.Height; h++ {
+			b := reader.GetAccountByHeight(a.Addr, h)
+			if b == nil && h == 0 {
+				// only genesis accounts start from height 0
+				continue
+			}
+			if b == nil {
+				return errors.New("account[" + a.Addr + "] block[" + strconv.Itoa(h) + "] not exist")
+			}
+			if h == a.Height && b.Hash() != a.Hash {
+				return errors.New("account[" + a.Addr + "] block[" + strconv.Itoa(h) + "] is not snapshotted")
+			}
+			self.applyAccount(b)
+		}
+		self.heads[a.Addr] = a.Height
+	}
+	self.hashH = common.HashHeight{Hash: block.Hash(), Height: block.Height()}
+	return nil
+}
+
+func (self *voteTally) applyAccount(block *common.AccountStateBlock) {
+	self.balances[block.Signer()] = block.Amount
+	if block.BlockType != common.SEND {
+		return
+	}
+	switch block.To {
+	case RegisterAddress.String():
+		self.candidates[block.From] = true
+	case VoteAddress.String():
+		if common.IsValidAddress(block.Data) {
+			self.votes[block.From] = block.Data
+		}
+	}
+}
+
+// top cnt candidates order by votes, members fill the rest if candidates are not enough
+func (self *voteTally) elect(cnt int, members []string) []string {
+	weights := make(map[string]int)
+	for voter, candidate := range self.votes {
+		if self.candidates[candidate] && self.balances[voter] > 0 {
+			weights[candidate] += self.balances[voter]
+		}
+	}
+	var candidates []string
+	for c := range weights {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := weights[candidates[i]], weights[candidates[j]]
+		if a != b {
+			return a > b
+		}
+		return candidates[i] < candidates[j]
+	})
+	var result []string
+	elected := make(map[string]bool)
+	for _, c := range append(candidates, members...) {
+		if len(result) >= cnt {
+			break
+		}
+		if elected[c] {
+			continue
+		}
+		elected[c] = true
+		result = append(result, c)
+	}
+	return result
+}
+
+// Fisher-Yates shuffle, random numbers come from sha256 of seed, index and position.
+// it doesn't depend on math/rand, so all nodes get the same order.
+func shuffle(members []string, seed string, index int32) []string {
+	result := make([]string, len(members))
+	copy(result, members)
+	prefix := seed + strconv.Itoa(int(index))
+	for i := len(result) - 1; i > 0; i-- {
+		sum := sha256.Sum256([]byte(prefix + ":" + strconv.Itoa(i)))
+		j := int(binary.BigEndian.Uint64(sum[:8]) % uint64(i+1))
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// tally votes on snapshot chain, cache the last tally for the next round
+type voteCounter struct {
+	reader face.ChainReader
+	last   *voteTally
+}
+
+func (self *voteCounter) tally(block *common.SnapshotBlock) (*voteTally, error) {
+	last := self.last
+	if last != nil {
+		b := self.reader.GetSnapshotByHeight(last.hashH.Height)
+		// forked or going back, tally again
+		if last.hashH.Height > block.Height() || b == nil || b.Hash() != last.hashH.Hash {
+			last = nil
+		}
+	}
+	if last == nil {
+		last = newVoteTally()
+	}
+	for h := last.hashH.Height + 1; h <= block.Height(); h++ {
+		b := self.reader.GetSnapshotByHeight(h)
+		if b == nil {
+			return nil, errors.New("snapshot block[" + strconv.Itoa(h) + "] not exist")
+		}
+		err := last.apply(self.reader, b)
+		if err != nil {
+			self.last = nil
+			return nil, err
+		}
+	}
+	if last.hashH.Hash != block.Hash() {
+		self.last = nil
+		return nil, errors.New("snapshot block[" + block.Hash() + "] is not on chain")
+	}
+	self.last = last
+	return last, nil
+}
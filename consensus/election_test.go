@@ -0,0 +1,171 @@
+package consensus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+func insertSend(t *testing.T, bc chain.BlockChain, from common.Address, to common.Address, data string) {
+	head, _ := bc.HeadAccount(from.String())
+	snapshot, _ := bc.HeadSnapshot()
+	block := common.NewAccountBlockFrom(head, from.String(), snapshot.Timestamp(), 0, snapshot,
+		common.SEND, from.String(), to.String(), "", -1)
+	block.Data = data
+	block.SetHash(tools.CalculateAccountHash(block))
+	if err := bc.InsertAccountBlock(from.String(), block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func insertSnapshot(t *testing.T, bc chain.BlockChain, timestamp time.Time) {
+	hashH, accounts, _ := bc.NextAccountSnapshot()
+	block := common.NewSnapshotBlock(hashH.Height+1, "", hashH.Hash, config.DevAddress(0).String(), timestamp, accounts)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addressStr(addrs []common.Address) []string {
+	var result []string
+	for _, a := range addrs {
+		result = append(result, a.String())
+	}
+	return result
+}
+
+// members are shuffled, only compare elected members
+func sameMembers(addrs []common.Address, expected ...string) bool {
+	if len(addrs) != len(expected) {
+		return false
+	}
+	set := make(map[string]bool)
+	for _, a := range addrs {
+		set[a.String()] = true
+	}
+	for _, e := range expected {
+		if !set[e] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestElection(t *testing.T) {
+	genesis := config.DefaultGenesis()
+	genesisTime := time.Unix(genesis.Timestamp, 0)
+	bc := chain.NewChain("", genesis)
+	dev0, dev1, dev5 := config.DevAddress(0), config.DevAddress(1), config.DevAddress(5)
+
+	// before round 1: dev5 and dev1 register, dev1 votes for itself, dev0 votes for dev5
+	insertSend(t, bc, dev5, RegisterAddress, "")
+	insertSend(t, bc, dev1, RegisterAddress, "")
+	insertSend(t, bc, dev1, VoteAddress, dev1.String())
+	insertSend(t, bc, dev0, VoteAddress, dev5.String())
+	insertSnapshot(t, bc, genesisTime.Add(time.Second))
+	// in round 1: dev0 changes vote to dev1
+	insertSend(t, bc, dev0, VoteAddress, dev1.String())
+	insertSnapshot(t, bc, genesisTime.Add(3*time.Second))
+
+	// 2 members every round, 2 seconds a round
+	teller := newTeller(genesisTime, 1, 2, genesis.Producers, bc)
+
+	r, _, final, err := teller.voteResults(1)
+	if err != nil || !final {
+		t.Fatal(err, final)
+	}
+	if !sameMembers(r, genesis.Producers[0], genesis.Producers[1]) {
+		t.Errorf("genesis producers should produce the first rounds. %v", addressStr(r))
+	}
+
+	r, seed, final, err := teller.voteResults(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final {
+		t.Error("round 2 should not be final before its snapshot block is buried or finalized.")
+	}
+	if !sameMembers(r, dev1.String(), dev5.String()) {
+		t.Errorf("round 2 members error. %v", addressStr(r))
+	}
+	// two rounds past the election time of round 2
+	insertSnapshot(t, bc, genesisTime.Add(6*time.Second))
+	r, seed, final, err = teller.voteResults(2)
+	if err != nil || !final || seed != 1 {
+		t.Fatal(err, final, seed)
+	}
+	if !sameMembers(r, dev1.String(), dev5.String()) {
+		t.Errorf("round 2 members error. %v", addressStr(r))
+	}
+
+	// votes of round 1 take effect, dev5 has no votes, genesis producer fills the committee
+	r, _, final, err = teller.voteResults(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final {
+		t.Error("round 3 should not be final before snapshot chain reaches its election time.")
+	}
+	if !sameMembers(r, dev1.String(), genesis.Producers[0]) {
+		t.Errorf("round 3 members error. %v", addressStr(r))
+	}
+	if teller.electionIndex(3) == nil || teller.electionHis[3] != nil {
+		t.Error("round 3 should not be cached.")
+	}
+
+	// another node computes the same plans from the same chain
+	other := newTeller(genesisTime, 1, 2, genesis.Producers, bc)
+	for i := int32(0); i < 4; i++ {
+		a, b := teller.electionIndex(i), other.electionIndex(i)
+		if len(a.plans) != len(b.plans) {
+			t.Fatalf("plans of round %d are different.", i)
+		}
+		for j := range a.plans {
+			if a.plans[j].member != b.plans[j].member || !a.plans[j].sTime.Equal(b.plans[j].sTime) {
+				t.Errorf("plans of round %d are different. %v %v", i, a.plans[j], b.plans[j])
+			}
+		}
+	}
+	if teller.electionHis[2] == nil {
+		t.Fatal("round 2 should be cached.")
+	}
+	// removal of the seed block elects round 2 again
+	teller.removeSeeded(2)
+	if teller.electionHis[2] == nil {
+		t.Error("round 2 is not seeded by removed blocks.")
+	}
+	teller.removeSeeded(1)
+	if teller.electionHis[2] != nil || teller.electionHis[1] == nil {
+		t.Error("only round 2 is seeded by removed blocks.")
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	var members []string
+	for i := 0; i < 10; i++ {
+		members = append(members, config.DevAddress(i).String())
+	}
+	a := shuffle(members, "seed", 1)
+	if b := shuffle(members, "seed", 1); strings.Join(a, ",") != strings.Join(b, ",") {
+		t.Error("shuffle is not deterministic.", a, b)
+	}
+	if !sameMembers(conv(a), members...) {
+		t.Error("shuffle lost members.", a)
+	}
+	if strings.Join(a, ",") == strings.Join(members, ",") {
+		t.Error("members are not shuffled.", a)
+	}
+	// 10! orders, different seeds or rounds nearly never give the same order
+	if b := shuffle(members, "seed", 2); strings.Join(a, ",") == strings.Join(b, ",") {
+		t.Error("round should change the order.", a)
+	}
+	if b := shuffle(members, "other", 1); strings.Join(a, ",") == strings.Join(b, ",") {
+		t.Error("seed should change the order.", a)
+	}
+}
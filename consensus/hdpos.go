@@ -1,46 +1,33 @@
 package consensus
 
 import (
+	"errors"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/vitelabs/go-vite/common/types"
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
 	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/common/log"
 )
 
-var DefaultMembers = []string{
-	"vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8",
-	"vite_1cb2ab2738cd913654658e879bef8115eb1aa61a9be9d15c3a",
-	"vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8",
-	"vite_85e8adb768aed85f2445eb1d71b933370d2980916baa3c1f3c",
-	"vite_93dd41694edd756512da7c4af429f3e875c374a53bfd217e00",
-	//"vite_f8dfcad17c08f9748271cce96eddc2b3732b399f6367597708",
-	//"vite_e77a71d44c65155e1474d708134c53c9dfb7af08b0299dc10d",
-	//"vite_1e7d413c276725c6a9e2f5fa8fadfa291e16ea3695c27760b1",
-	//"vite_946e7f9554cc5efc6e404a6510c3c6a098ac4296c049cc348b",
-	//"vite_d6bf8cf4b590c8e7bfc846463e4b67522d5dda3040d4ff83e8",
-	//"vite_ed143aa15a2bfb039b903e7368a297dc0a11288b4af7d7ceb5",
-	//"vite_559ddc6918f287316a93ed063ca64621fe2e33a45457c90c11",
-	//"vite_bdcb0d7ab9b3db2b229b70613f6a3d18c083457fae3289e926",
-	//"vite_080d19e9edf8365e79a7a3e57d253f072648c843d1f7084818",
-	//"vite_771e5d643432cd3a50249ed42d1e09c198f1ebd0425f8090e4",
-	//"vite_0575741ef5b9fe23a107cc8e2affbd6799088f2debab8264af",
-	//"vite_bae6cb6e8dcaa3cd7475fdf5520f14c0556bf9b0950aed0fd4",
-	//"vite_cd585066ed35c1e27c9ac32a8fe13c6a1addbbe21e9badc84c",
-	//"vite_98fe74d859935cbf833b876599abd9fc4334719cd62d039b5e",
-	//"vite_d1f21f486b4ae94e2f3ce237e620ad524fbd6c1313275ef64c",
-	//"vite_c873add4fada0029da924ec3557bd838a8de2220917da10569",
-	//"vite_323033f4d510ee4ff9f0d4be1d71d63372c1c15d0c9001f041",
-	//"vite_7a10c045903421df0915dd8d5807d37d2dff343df961365ef7",
-	//"vite_c84d89b7adabaac7d86bd318f988ea39568a90eeafd3eece18",
-	//"vite_8c52581f7498350be103b0f0ea20a4f66fde1193bd6668d04e",
-}
+var DefaultMembers = config.DefaultProducers
+
+// rounds a snapshot block is buried under before the election it seeds is final
+const finalRounds = 2
 
 func conv(mems []string) []common.Address {
-	addressArr := make([]common.Address, len(mems))
-	for i, v := range mems {
-		addressArr[i] = common.HexToAddress(v)
+	addressArr := make([]common.Address, 0, len(mems))
+	for _, v := range mems {
+		addr, err := common.ParseAddress(v)
+		if err != nil {
+			log.Error("member address error. err:%v", err)
+			continue
+		}
+		addressArr = append(addressArr, addr)
 	}
 	return addressArr
 }
@@ -50,9 +37,7 @@ type SubscribeMem struct {
 	Notify chan time.Time
 }
 
-// SignerFn is a signer callback function to request a hash to be signed by a
-// backing account.
-type SignerFn func(a common.Address, data []byte) (signedData, pubkey []byte, err error)
+type SignerFn = common.SignerFn
 
 // update committee result
 type Committee struct {
@@ -60,9 +45,12 @@ type Committee struct {
 	interval     int
 	memberCnt    int
 	teller       *teller
-	subscribeMem *SubscribeMem
+	subscribeMem map[string]*SubscribeMem
+	subMu        sync.Mutex
 	signer       common.Address
 	signerFn     SignerFn
+	clock        clock.Clock
+	tracker      *producerTracker
 }
 
 func (self *Committee) Seal() error {
@@ -74,36 +62,52 @@ func (self *Committee) Authorize(signer common.Address, fn SignerFn) {
 }
 
 func (self *Committee) Verify(reader SnapshotReader, header *common.SnapshotBlock) (bool, error) {
-	result, err := self.verifyProducer(header)
-	if result && err == nil {
-		return true, nil
+	// only one block in a slot
+	if reader != nil {
+		prev := reader.GetSnapshotByHash(header.PreHash())
+		if prev != nil && !header.Timestamp().After(prev.Timestamp()) {
+			return false, errors.New("block time[" + header.Timestamp().Format(time.RFC3339) +
+				"] is not after previous block time[" + prev.Timestamp().Format(time.RFC3339) + "]")
+		}
 	}
-	return false, nil
+	return self.verifyProducer(header)
 }
 
 func (self *Committee) verifyProducer(header *common.SnapshotBlock) (bool, error) {
-	electionResult := self.teller.electionTime(time.Unix(int64(header.Timestamp().Unix()), 0))
+	t := time.Unix(int64(header.Timestamp().Unix()), 0)
+	electionResult := self.teller.electionTime(t)
+	if electionResult == nil {
+		return false, errors.New("can't get election result of time[" + t.Format(time.RFC3339) + "]")
+	}
 
 	for _, plan := range electionResult.plans {
 		if plan.member.String() == header.Signer() {
 			if uint64(plan.sTime.Unix()) == uint64(header.Timestamp().Unix()) {
 				return true, nil
 			} else {
-				return false, nil
+				return false, errors.New("slot of producer[" + header.Signer() + "] is [" + plan.sTime.Format(time.RFC3339) +
+					"], but block time is [" + t.Format(time.RFC3339) + "]")
 			}
 		}
 	}
-	return false, nil
+	return false, errors.New("signer[" + header.Signer() + "] is not a producer of round[" +
+		strconv.Itoa(int(electionResult.index)) + "]")
 }
 
 func NewCommittee(genesisTime time.Time, interval int32, memberCnt int32) *Committee {
-	committee := &Committee{interval: int(interval), memberCnt: int(memberCnt)}
-	committee.teller = newTeller(genesisTime, interval, memberCnt)
+	committee := &Committee{interval: int(interval), memberCnt: int(memberCnt), clock: clock.NewRealClock()}
+	committee.teller = newTeller(genesisTime, interval, memberCnt, DefaultMembers, nil)
+	committee.tracker = newProducerTracker(committee.teller, committee.clock)
 	return committee
 }
-func NewConsensus(genesisTime time.Time, cfg config.Consensus) Consensus {
-	committee := &Committee{interval: cfg.Interval, memberCnt: cfg.MemCnt}
-	committee.teller = newTeller(genesisTime, int32(cfg.Interval), int32(cfg.MemCnt))
+
+// producers come from genesis, they fill the committee if elected candidates are not enough.
+// candidates are registered and voted by account blocks of reader.
+// slots are scheduled by clk.
+func NewConsensus(genesisTime time.Time, producers []string, reader face.ChainReader, cfg config.Consensus, clk clock.Clock) Consensus {
+	committee := &Committee{interval: cfg.Interval, memberCnt: cfg.MemCnt, clock: clk}
+	committee.teller = newTeller(genesisTime, int32(cfg.Interval), int32(cfg.MemCnt), producers, reader)
+	committee.tracker = newProducerTracker(committee.teller, clk)
 	return committee
 }
 
@@ -124,60 +128,116 @@ func (self *Committee) Stop() {
 	defer self.PostStop()
 }
 
+// subscribe slots of subscribeMem.Mem, the former subscription of the same member is replaced
 func (self *Committee) Subscribe(subscribeMem *SubscribeMem) {
-	self.subscribeMem = subscribeMem
+	self.subMu.Lock()
+	defer self.subMu.Unlock()
+	if self.subscribeMem == nil {
+		self.subscribeMem = make(map[string]*SubscribeMem)
+	}
+	self.subscribeMem[subscribeMem.Mem.String()] = subscribeMem
+}
+
+func (self *Committee) UnSubscribe(mem common.Address) {
+	self.subMu.Lock()
+	defer self.subMu.Unlock()
+	delete(self.subscribeMem, mem.String())
+}
+
+func (self *Committee) subscribed() map[string]*SubscribeMem {
+	self.subMu.Lock()
+	defer self.subMu.Unlock()
+	result := make(map[string]*SubscribeMem, len(self.subscribeMem))
+	for k, v := range self.subscribeMem {
+		result[k] = v
+	}
+	return result
+}
+
+func (self *Committee) Schedule(from time.Time, to time.Time) ([]*Slot, error) {
+	return self.teller.schedule(from, to)
+}
+
+func (self *Committee) Stat(rounds int) []*RoundStat {
+	return self.tracker.stat(rounds)
+}
+
+// members of the round which t belongs to, in slot order
+func (self *Committee) Members(t time.Time) ([]common.Address, error) {
+	electionResult := self.teller.electionTime(t)
+	if electionResult == nil {
+		return nil, errors.New("can't get election result of time[" + t.Format(time.RFC3339) + "]")
+	}
+	var result []common.Address
+	for _, plan := range electionResult.plans {
+		result = append(result, plan.member)
+	}
+	return result, nil
+}
+
+func (self *Committee) SnapshotInsertCallback(block *common.SnapshotBlock) {
+	self.tracker.inserted(block)
+}
+
+func (self *Committee) SnapshotRemoveCallback(block *common.SnapshotBlock) {
+	self.tracker.removed(block)
+	self.teller.removeSeeded(block.Height())
+}
+
+func (self *Committee) AccountInsertCallback(address string, block *common.AccountStateBlock) {
+}
+
+func (self *Committee) AccountRemoveCallback(address string, block *common.AccountStateBlock) {
 }
 
 func (self *Committee) update() {
 	var lastIndex int32 = -1
-	var lastRemoveTime = time.Now()
+	var lastRemoveTime = self.clock.Now()
 	for !self.Stopped() {
-		var current *memberPlan = nil
-		electionResult := self.teller.electionTime(time.Now())
+		electionResult := self.teller.electionTime(self.clock.Now())
 
 		if electionResult == nil {
-			log.Error("can't get election result. time is " + time.Now().Format(time.RFC3339Nano) + "\".")
-			time.Sleep(time.Duration(self.interval) * time.Second)
+			log.Error("can't get election result. time is " + self.clock.Now().Format(time.RFC3339Nano) + "\".")
+			self.clock.Sleep(time.Duration(self.interval) * time.Second)
 			// error handle
 			continue
 		}
 
 		if electionResult.index <= lastIndex {
-			time.Sleep(electionResult.eTime.Sub(time.Now()))
+			self.clock.Sleep(electionResult.eTime.Sub(self.clock.Now()))
 			continue
 		}
-		mem := self.subscribeMem
-		if mem == nil {
-			time.Sleep(electionResult.eTime.Sub(time.Now()))
+		mems := self.subscribed()
+		if len(mems) == 0 {
+			self.clock.Sleep(electionResult.eTime.Sub(self.clock.Now()))
 			continue
 		}
 
-		plans := electionResult.plans
-		for _, plan := range plans {
-			if plan.member.String() == mem.Mem.String() {
-				current = plan
-				break
-			}
-		}
-
-		if current != nil && lastIndex != -1 {
-			time.Sleep(current.sTime.Sub(time.Now()))
-
-			// write timeout
-			select {
-			case mem.Notify <- current.sTime:
-			case <-time.After(electionResult.eTime.Sub(time.Now())):
-				log.Error("timeout for notify miner. miner time is \"" + current.sTime.Format(time.RFC3339Nano) + "\".")
-				continue
+		if lastIndex != -1 {
+			// notify subscribed members one by one in slot order
+			for _, plan := range electionResult.plans {
+				mem, ok := mems[plan.member.String()]
+				if !ok {
+					continue
+				}
+				self.clock.Sleep(plan.sTime.Sub(self.clock.Now()))
+
+				// write timeout
+				select {
+				case mem.Notify <- plan.sTime:
+				case <-self.clock.After(electionResult.eTime.Sub(self.clock.Now())):
+					log.Error("timeout for notify miner. miner time is \"" + plan.sTime.Format(time.RFC3339Nano) + "\".")
+				}
+				if !self.clock.Now().Before(electionResult.eTime) {
+					break
+				}
 			}
-			time.Sleep(electionResult.eTime.Sub(time.Now()))
-		} else {
-			time.Sleep(electionResult.eTime.Sub(time.Now()))
 		}
+		self.clock.Sleep(electionResult.eTime.Sub(self.clock.Now()))
 		lastIndex = electionResult.index
 
 		// clear ever hour
-		removeTime := time.Now().Add(-time.Hour)
+		removeTime := self.clock.Now().Add(-time.Hour)
 		if lastRemoveTime.Before(removeTime) {
 			self.teller.removePrevious(removeTime)
 			lastRemoveTime = removeTime
@@ -202,12 +262,13 @@ type electionResult struct {
 	sTime time.Time
 	eTime time.Time
 	index int32
+	final bool // members and order will not change
+	seed  int  // height of the snapshot block which elects and shuffles members
 }
 
 func (self *membersInfo) genPlan(index int32, members []common.Address) *electionResult {
 	result := electionResult{}
-	planInterval := self.interval * self.memberCnt
-	var sTime time.Time = self.genesisTime.Add(time.Duration(planInterval*index) * time.Second)
+	var sTime time.Time = self.index2Time(index)
 	result.sTime = sTime
 	plans := make([]*memberPlan, 0, len(members))
 	for _, member := range members {
@@ -221,6 +282,12 @@ func (self *membersInfo) genPlan(index int32, members []common.Address) *electio
 	return &result
 }
 
+// start time of round index
+func (self *membersInfo) index2Time(index int32) time.Time {
+	planInterval := self.interval * self.memberCnt
+	return self.genesisTime.Add(time.Duration(planInterval*index) * time.Second)
+}
+
 func (self *membersInfo) time2Index(t time.Time) int32 {
 	subSec := int64(t.Sub(self.genesisTime).Seconds())
 	i := subSec / int64((self.interval * self.memberCnt))
@@ -230,32 +297,106 @@ func (self *membersInfo) time2Index(t time.Time) int32 {
 // Ensure that all nodes get same result
 type teller struct {
 	info        *membersInfo
+	members     []string
+	reader      face.ChainReader // nil: members are fixed
+	counter     *voteCounter
 	electionHis map[int32]*electionResult
+	// used by update loop and verifier
+	mu sync.Mutex
 }
 
-func newTeller(genesisTime time.Time, interval int32, memberCnt int32) *teller {
-	t := &teller{}
+func newTeller(genesisTime time.Time, interval int32, memberCnt int32, members []string, reader face.ChainReader) *teller {
+	t := &teller{members: members, reader: reader}
 	t.info = &membersInfo{genesisTime: genesisTime, memberCnt: memberCnt, interval: interval}
 	t.electionHis = make(map[int32]*electionResult)
+	if reader != nil {
+		t.counter = &voteCounter{reader: reader}
+	}
 	return t
 }
 
-func (self *teller) voteResults() ([]common.Address, error) {
-	// record vote and elect
-	return conv(DefaultMembers), nil
+// members of round index are elected by votes snapshotted before the start of round index-1,
+// so all nodes have received these snapshot blocks when the round begins.
+// members are shuffled by the hash of that snapshot block, nobody knows the order before it's produced.
+// seed is the height of that snapshot block, final is false if it may still be replaced.
+func (self *teller) voteResults(index int32) ([]common.Address, int, bool, error) {
+	if self.reader == nil {
+		return conv(newVoteTally().elect(int(self.info.memberCnt), self.members)), 0, true, nil
+	}
+	if index < 2 {
+		genesis, err := self.reader.GenesisSnapshot()
+		if err != nil {
+			return nil, 0, false, err
+		}
+		members := newVoteTally().elect(int(self.info.memberCnt), self.members)
+		return conv(shuffle(members, genesis.Hash(), index)), genesis.Height(), true, nil
+	}
+	block, final, err := self.voteSnapshot(self.info.index2Time(index - 1))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	tally, err := self.counter.tally(block)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	members := tally.elect(int(self.info.memberCnt), self.members)
+	return conv(shuffle(members, block.Hash(), index)), block.Height(), final, nil
+}
+
+// the last snapshot block before t.
+// it's final when all snapshot blocks before t are finalized, or local snapshot chain is finalRounds rounds past t.
+// final elections are cached until their seed block is removed.
+func (self *teller) voteSnapshot(t time.Time) (*common.SnapshotBlock, bool, error) {
+	head, err := self.reader.HeadSnapshot()
+	if err != nil {
+		return nil, false, err
+	}
+	if head.Timestamp().Before(t) {
+		return head, false, nil
+	}
+	final := !self.reader.FinalizedSnapshot().Timestamp().Before(t) ||
+		!head.Timestamp().Before(t.Add(time.Duration(finalRounds*self.info.interval*self.info.memberCnt)*time.Second))
+	// timestamp of snapshot blocks is increasing, genesis is before t
+	lo, hi := 0, head.Height()
+	var result *common.SnapshotBlock
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		b := self.reader.GetSnapshotByHeight(mid)
+		if b == nil {
+			return nil, false, errors.New("snapshot block[" + strconv.Itoa(mid) + "] not exist")
+		}
+		if b.Timestamp().Before(t) {
+			result = b
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if result == nil {
+		return nil, false, errors.New("no snapshot block before " + t.Format(time.RFC3339))
+	}
+	return result, final, nil
 }
 
 func (self *teller) electionIndex(index int32) *electionResult {
+	self.mu.Lock()
+	defer self.mu.Unlock()
 	result, ok := self.electionHis[index]
 	if ok {
 		return result
 	} else {
-		voteResults, err := self.voteResults()
+		voteResults, seed, final, err := self.voteResults(index)
 		if err == nil {
 			plans := self.info.genPlan(index, voteResults)
-			self.electionHis[index] = plans
+			plans.final = final
+			plans.seed = seed
+			// result and order may change when more snapshot blocks arrive
+			if final {
+				self.electionHis[index] = plans
+			}
 			return plans
 		}
+		log.Error("election of index[%d] fail. err:%v", index, err)
 	}
 	return nil
 }
@@ -264,7 +405,21 @@ func (self *teller) electionTime(t time.Time) *electionResult {
 	index := self.info.time2Index(t)
 	return self.electionIndex(index)
 }
+
+// rounds seeded by removed snapshot blocks are elected again
+func (self *teller) removeSeeded(height int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for k, v := range self.electionHis {
+		if v.seed >= height {
+			delete(self.electionHis, k)
+		}
+	}
+}
+
 func (self *teller) removePrevious(rtime time.Time) int32 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
 	var i int32 = 0
 	for k, v := range self.electionHis {
 		if v.eTime.Before(rtime) {
@@ -1,16 +1,19 @@
 package consensus
 
 import (
-	"github.com/viteshan/naive-vite/common"
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/config"
 )
 
 func genAddress(n int) []common.Address {
 	addressArr := make([]common.Address, n)
 	for i := 0; i < n; i++ {
-		addressArr[i] = common.HexToAddress("viteshan" + strconv.Itoa(i))
+		addressArr[i] = config.DevAddress(i)
 	}
 	return addressArr
 }
@@ -47,13 +50,101 @@ func TestTime2Index(t *testing.T) {
 
 }
 
+func expectNotify(t *testing.T, mem *SubscribeMem, expected time.Time) {
+	select {
+	case n := <-mem.Notify:
+		if !n.Equal(expected) {
+			t.Errorf("notify time error. %s, expected %s", n.Format(time.RFC3339), expected.Format(time.RFC3339))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no notify for %s", expected.Format(time.RFC3339))
+	}
+}
+
+func expectNoNotify(t *testing.T, mem *SubscribeMem) {
+	select {
+	case n := <-mem.Notify:
+		t.Errorf("unexpected notify %s", n.Format(time.RFC3339))
+	default:
+	}
+}
+
 func TestUpdate(t *testing.T) {
-	address := genAddress(1)
-	mem := SubscribeMem{Mem: address[0], Notify: make(chan time.Time)}
-	committee := NewCommittee(time.Now(), 1, 4)
-	committee.Subscribe(&mem)
+	genesisTime := time.Unix(1533550878, 0)
+	clk := clock.NewManualClock(genesisTime.Add(2 * time.Second))
+	// 5 members, 1 second a slot, members are not shuffled without chain
+	cs := NewConsensus(genesisTime, DefaultMembers, nil, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	mem := &SubscribeMem{Mem: genAddress(2)[1], Notify: make(chan time.Time)}
+	cs.Subscribe(mem)
+	cs.Init()
+	cs.Start()
+	defer cs.Stop()
 
-	committee.update()
+	// the first round is skipped
+	clk.BlockUntil(1)
+	expectNoNotify(t, mem)
+	clk.Add(3 * time.Second)
+
+	// round 1 starts at 5s, slot of member 1 is 6s
+	clk.BlockUntil(1)
+	expectNoNotify(t, mem)
+	clk.Add(time.Second)
+	expectNotify(t, mem, genesisTime.Add(6*time.Second))
+
+	// sleep to the end of round 1
+	clk.BlockUntil(1)
+	clk.Add(4 * time.Second)
+	clk.BlockUntil(1)
+	expectNoNotify(t, mem)
+	clk.Add(time.Second)
+	expectNotify(t, mem, genesisTime.Add(11*time.Second))
+
+	// nobody receives notify of round 3, it times out at the end of round
+	clk.BlockUntil(1)
+	clk.Add(4 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(time.Second)
+	clk.Add(4 * time.Second)
+
+	// round 4 goes on
+	clk.BlockUntil(1)
+	expectNoNotify(t, mem)
+	clk.Add(time.Second)
+	expectNotify(t, mem, genesisTime.Add(21*time.Second))
+}
+
+func TestUpdateMultiple(t *testing.T) {
+	genesisTime := time.Unix(1533550878, 0)
+	clk := clock.NewManualClock(genesisTime.Add(4 * time.Second))
+	cs := NewConsensus(genesisTime, DefaultMembers, nil, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	addrs := genAddress(4)
+	notify := make(chan time.Time)
+	cs.Subscribe(&SubscribeMem{Mem: addrs[1], Notify: notify})
+	cs.Subscribe(&SubscribeMem{Mem: addrs[3], Notify: notify})
+	mem := &SubscribeMem{Notify: notify}
+	cs.Init()
+	cs.Start()
+	defer cs.Stop()
+
+	// round 1 starts at 5s, slots of member 1 and 3 are 6s and 8s
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectNotify(t, mem, genesisTime.Add(6*time.Second))
+	clk.BlockUntil(1)
+	expectNoNotify(t, mem)
+	clk.Add(2 * time.Second)
+	expectNotify(t, mem, genesisTime.Add(8*time.Second))
+
+	// member 3 leaves from round 2
+	cs.UnSubscribe(addrs[3])
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(time.Second)
+	expectNotify(t, mem, genesisTime.Add(11*time.Second))
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectNoNotify(t, mem)
 }
 func TestGen(t *testing.T) {
 	address := genAddress(4)
@@ -63,11 +154,14 @@ func TestGen(t *testing.T) {
 }
 
 func TestRemovePrevious(t *testing.T) {
-	teller := newTeller(time.Now(), 1, 4)
+	genesisTime := time.Unix(1533550878, 0)
+	// 4 seconds a round
+	teller := newTeller(genesisTime, 1, 4, DefaultMembers, nil)
 	for i := 0; i < 10; i++ {
 		teller.electionIndex(int32(i))
 	}
-	time.Sleep(10 * time.Second)
-	cnt := teller.removePrevious(time.Now())
-	println("个数:\t" + strconv.Itoa(int(cnt)))
+	cnt := teller.removePrevious(genesisTime.Add(10 * time.Second))
+	if cnt != 2 || len(teller.electionHis) != 8 {
+		t.Errorf("remove previous error. removed:%d, rest:%d", cnt, len(teller.electionHis))
+	}
 }
@@ -0,0 +1,47 @@
+package consensus
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+// rounds of a schedule query at most
+const maxScheduleRounds = 1000
+
+type Slot struct {
+	STime  time.Time
+	Member common.Address
+	// false if votes of the round are not snapshotted yet, member and order may change
+	Final bool
+}
+
+func (self *teller) schedule(from time.Time, to time.Time) ([]*Slot, error) {
+	if from.Before(self.info.genesisTime) {
+		from = self.info.genesisTime
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+	fromIndex := self.info.time2Index(from)
+	toIndex := self.info.time2Index(to.Add(-time.Nanosecond))
+	if toIndex-fromIndex >= maxScheduleRounds {
+		return nil, errors.New("schedule is too long, rounds:" + strconv.Itoa(int(toIndex-fromIndex+1)) +
+			", max:" + strconv.Itoa(maxScheduleRounds))
+	}
+	var result []*Slot
+	for i := fromIndex; i <= toIndex; i++ {
+		election := self.electionIndex(i)
+		if election == nil {
+			return nil, errors.New("can't get election result of round[" + strconv.Itoa(int(i)) + "]")
+		}
+		for _, p := range election.plans {
+			if !p.sTime.Before(from) && p.sTime.Before(to) {
+				result = append(result, &Slot{STime: p.sTime, Member: p.member, Final: election.final})
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package consensus
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common/config"
+)
+
+func TestSchedule(t *testing.T) {
+	genesis := config.DefaultGenesis()
+	genesisTime := time.Unix(genesis.Timestamp, 0)
+	bc := chain.NewChain("", genesis)
+	teller := newTeller(genesisTime, 1, 5, genesis.Producers, bc)
+
+	slots, err := teller.schedule(genesisTime.Add(-time.Hour), genesisTime.Add(12*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 12 {
+		t.Fatalf("slots error. %d", len(slots))
+	}
+	for i, s := range slots {
+		if !s.STime.Equal(genesisTime.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("slot %d time error. %s", i, s.STime)
+		}
+		plan := teller.electionTime(s.STime).plans[i%5]
+		if s.Member != plan.member {
+			t.Errorf("slot %d member error. %s, expected %s", i, s.Member, plan.member)
+		}
+		// round 2 is elected by snapshot blocks which don't exist yet
+		if s.Final != (i < 10) {
+			t.Errorf("slot %d final error. %t", i, s.Final)
+		}
+	}
+
+	slots, _ = teller.schedule(genesisTime.Add(3*time.Second), genesisTime.Add(7*time.Second))
+	if len(slots) != 4 || !slots[0].STime.Equal(genesisTime.Add(3*time.Second)) {
+		t.Errorf("slots in range error. %d", len(slots))
+	}
+	if _, err := teller.schedule(genesisTime, genesisTime.Add(time.Duration(5*maxScheduleRounds)*time.Second)); err != nil {
+		t.Error("max rounds should be allowed.", err)
+	}
+	if _, err := teller.schedule(genesisTime, genesisTime.Add(time.Duration(5*maxScheduleRounds+1)*time.Second)); err == nil {
+		t.Error("too long schedule should fail.")
+	}
+
+	// queried by shell while update loop removes old rounds
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				teller.schedule(genesisTime, genesisTime.Add(20*time.Second))
+				teller.removePrevious(genesisTime.Add(time.Duration(i*j) * time.Second))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
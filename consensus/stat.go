@@ -0,0 +1,163 @@
+package consensus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/log"
+)
+
+const maxStatRounds = 1000
+
+type ProducerStat struct {
+	Member   string
+	Produced int // inserted in its slot
+	Late     int // block of its slot, but inserted after the slot
+	Missed   int // slot passed without block
+}
+
+type RoundStat struct {
+	Index     int32
+	STime     time.Time
+	ETime     time.Time
+	Producers []*ProducerStat // order by slot
+}
+
+// sum stats of rounds by producer, order by member
+func SumStat(rounds []*RoundStat) []*ProducerStat {
+	sum := make(map[string]*ProducerStat)
+	for _, r := range rounds {
+		for _, p := range r.Producers {
+			s, ok := sum[p.Member]
+			if !ok {
+				s = &ProducerStat{Member: p.Member}
+				sum[p.Member] = s
+			}
+			s.Produced += p.Produced
+			s.Late += p.Late
+			s.Missed += p.Missed
+		}
+	}
+	var result []*ProducerStat
+	for _, s := range sum {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Member < result[j].Member
+	})
+	return result
+}
+
+// compare inserted snapshot blocks with the plans of teller.
+// rounds before tracker started are not tracked, blocks of them come from syncing.
+type producerTracker struct {
+	teller   *teller
+	clock    clock.Clock
+	interval time.Duration
+	start    int32
+	// round index -> slot time -> late
+	rounds map[int32]map[int64]bool
+	mu     sync.Mutex
+}
+
+func newProducerTracker(teller *teller, clk clock.Clock) *producerTracker {
+	return &producerTracker{
+		teller:   teller,
+		clock:    clk,
+		interval: time.Duration(teller.info.interval) * time.Second,
+		start:    teller.info.time2Index(clk.Now()),
+		rounds:   make(map[int32]map[int64]bool),
+	}
+}
+
+func (self *producerTracker) plan(block *common.SnapshotBlock) (int32, *memberPlan) {
+	index := self.teller.info.time2Index(block.Timestamp())
+	if index < self.start {
+		return index, nil
+	}
+	result := self.teller.electionIndex(index)
+	if result == nil {
+		return index, nil
+	}
+	for _, p := range result.plans {
+		if p.member.String() == block.Signer() && p.sTime.Equal(block.Timestamp()) {
+			return index, p
+		}
+	}
+	log.Warn("snapshot block[%s][%d][%s] is not in plans.", block.Signer(), block.Height(), block.Hash())
+	return index, nil
+}
+
+func (self *producerTracker) inserted(block *common.SnapshotBlock) {
+	if block.Height() == 0 {
+		return
+	}
+	index, plan := self.plan(block)
+	if plan == nil {
+		return
+	}
+	late := self.clock.Now().After(plan.sTime.Add(self.interval))
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	slots, ok := self.rounds[index]
+	if !ok {
+		slots = make(map[int64]bool)
+		self.rounds[index] = slots
+	}
+	slots[plan.sTime.Unix()] = late
+	delete(self.rounds, index-maxStatRounds)
+}
+
+func (self *producerTracker) removed(block *common.SnapshotBlock) {
+	index, plan := self.plan(block)
+	if plan == nil {
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if slots, ok := self.rounds[index]; ok {
+		delete(slots, plan.sTime.Unix())
+	}
+}
+
+// stats of the last rounds, current round included
+func (self *producerTracker) stat(rounds int) []*RoundStat {
+	now := self.clock.Now()
+	current := self.teller.info.time2Index(now)
+	from := current - int32(rounds) + 1
+	if from < self.start {
+		from = self.start
+	}
+	if from < current-maxStatRounds+1 {
+		from = current - maxStatRounds + 1
+	}
+	var result []*RoundStat
+	for i := from; i <= current; i++ {
+		election := self.teller.electionIndex(i)
+		if election == nil {
+			continue
+		}
+		r := &RoundStat{Index: i, STime: election.sTime, ETime: election.eTime}
+		self.mu.Lock()
+		slots := self.rounds[i]
+		for _, p := range election.plans {
+			s := &ProducerStat{Member: p.member.String()}
+			late, produced := slots[p.sTime.Unix()]
+			if produced && late {
+				s.Late++
+			} else if produced {
+				s.Produced++
+			} else if !now.Before(p.sTime.Add(self.interval)) {
+				s.Missed++
+			}
+			r.Producers = append(r.Producers, s)
+		}
+		self.mu.Unlock()
+		result = append(result, r)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+func produce(t *testing.T, bc chain.BlockChain, plan *memberPlan) *common.SnapshotBlock {
+	head, _ := bc.HeadSnapshot()
+	block := common.NewSnapshotBlock(head.Height()+1, "", head.Hash(), plan.member.String(), plan.sTime, nil)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if err := bc.InsertSnapshotBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func findStat(stats []*ProducerStat, member common.Address) *ProducerStat {
+	for _, s := range stats {
+		if s.Member == member.String() {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestProducerStat(t *testing.T) {
+	genesis := config.DefaultGenesis()
+	genesisTime := time.Unix(genesis.Timestamp, 0)
+	bc := chain.NewChain("", genesis)
+	clk := clock.NewManualClock(genesisTime)
+	cs := NewConsensus(genesisTime, genesis.Producers, bc, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	bc.AddChainListener(cs)
+	plans := cs.(*Committee).teller.electionIndex(0).plans
+
+	// produced in slot, missed, produced after slot
+	clk.Set(plans[0].sTime.Add(500 * time.Millisecond))
+	produce(t, bc, plans[0])
+	clk.Set(plans[2].sTime.Add(2 * time.Second))
+	late := produce(t, bc, plans[2])
+	if late.Height() != 2 {
+		t.Fatal("block height error.", late.Height())
+	}
+
+	// round 0 ends
+	clk.Set(genesisTime.Add(5 * time.Second))
+	stats := cs.Stat(2)
+	if len(stats) != 2 || stats[0].Index != 0 || stats[1].Index != 1 {
+		t.Fatalf("rounds error. %v", stats)
+	}
+	expected := []ProducerStat{{Produced: 1}, {Missed: 1}, {Late: 1}, {Missed: 1}, {Missed: 1}}
+	for i, p := range stats[0].Producers {
+		e := expected[i]
+		if p.Member != plans[i].member.String() || p.Produced != e.Produced || p.Late != e.Late || p.Missed != e.Missed {
+			t.Errorf("stat of slot %d error. %v, expected %v", i, *p, e)
+		}
+	}
+	for _, p := range stats[1].Producers {
+		if p.Produced+p.Late+p.Missed != 0 {
+			t.Errorf("no slot of round 1 has passed. %v", *p)
+		}
+	}
+
+	// rolled back block becomes missed
+	if err := bc.RemoveSnapshotHead(late); err != nil {
+		t.Fatal(err)
+	}
+	sum := SumStat(cs.Stat(1000))
+	if s := findStat(sum, plans[2].member); s == nil || s.Late != 0 || s.Missed != 1 {
+		t.Errorf("removed block should be missed. %v", s)
+	}
+	if s := findStat(sum, plans[0].member); s == nil || s.Produced != 1 {
+		t.Errorf("produced block error. %v", s)
+	}
+}
@@ -33,6 +33,10 @@ func (self *TestSyncer) Done() bool {
 	return true
 }
 
+func (self *TestSyncer) Behind() int {
+	return 0
+}
+
 func (self *TestSyncer) BroadcastAccountBlocks(string, []*common.AccountStateBlock) error {
 	return nil
 }
@@ -41,6 +45,10 @@ func (self *TestSyncer) BroadcastSnapshotBlocks([]*common.SnapshotBlock) error {
 	return nil
 }
 
+func (self *TestSyncer) BroadcastEvidences([]*common.Evidence) error {
+	return nil
+}
+
 func (self *TestSyncer) SendAccountBlocks(string, []*common.AccountStateBlock, p2p.Peer) error {
 	panic("implement me")
 }
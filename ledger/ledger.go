@@ -8,6 +8,7 @@ import (
 	"github.com/viteshan/naive-vite/chain"
 	"github.com/viteshan/naive-vite/common"
 	"github.com/viteshan/naive-vite/common/log"
+	"github.com/viteshan/naive-vite/consensus"
 	"github.com/viteshan/naive-vite/pool"
 	"github.com/viteshan/naive-vite/syncer"
 	"github.com/viteshan/naive-vite/tools"
@@ -18,13 +19,22 @@ type Ledger interface {
 	// from self
 	RequestAccountBlock(from string, to string, amount int) error
 	ResponseAccountBlock(from string, to string, reqHash string) error
+	// register from as a producer candidate
+	RegisterAccountBlock(from string) error
+	// vote for a registered producer candidate by the balance of from
+	VoteAccountBlock(from string, candidate string) error
 	// create account genesis block
 	GetAccountBalance(address string) int
 
 	ListRequest(address string) []*Req
 	Start()
 	Stop()
-	Init(syncer syncer.Syncer)
+	// snapshot blocks from network are checked by cv
+	Init(syncer syncer.Syncer, cv consensus.ConsensusVerifier)
+	// blocks created by ledger are signed by fn
+	SetSignerFn(fn common.SignerFn)
+	// accounts of mined snapshot blocks are chosen by policy
+	SetPackingPolicy(policy chain.PackingPolicy)
 
 	ListAccountBlock(address string) []*common.AccountStateBlock
 	ListSnapshotBlock() []*common.SnapshotBlock
@@ -37,8 +47,10 @@ type ledger struct {
 	reqPool *reqPool
 	bpool   pool.BlockPool
 
-	syncer  syncer.Syncer
-	rwMutex *sync.RWMutex
+	syncer   syncer.Syncer
+	signerFn common.SignerFn
+	packing  chain.PackingPolicy
+	rwMutex  *sync.RWMutex
 }
 
 func (self *ledger) GetAccountBalance(address string) int {
@@ -63,15 +75,22 @@ func (self *ledger) MiningSnapshotBlock(address string, timestamp int64) error {
 	self.rwMutex.Lock()
 	defer self.rwMutex.Unlock()
 
-	hashH, accounts, err := self.bc.NextAccountSnapshot()
+	hashH, candidates, err := self.bc.SnapshotCandidates()
 
 	if err != nil {
 		log.Error("get next accounts snapshot err. ", err)
 		return err
 	}
+	// the rest accounts are snapshotted by next blocks
+	accounts := self.packing.Pack(candidates)
 
 	block := common.NewSnapshotBlock(hashH.Height+1, "", hashH.Hash, address, time.Unix(timestamp, 0), accounts)
 	block.SetHash(tools.CalculateSnapshotHash(block))
+	err = tools.SignBlock(block, self.signerFn)
+	if err != nil {
+		log.Error("sign snapshot block error. ", err)
+		return err
+	}
 
 	err = self.bpool.AddDirectSnapshotBlock(block)
 	if err != nil {
@@ -83,19 +102,55 @@ func (self *ledger) MiningSnapshotBlock(address string, timestamp int64) error {
 }
 
 func (self *ledger) RequestAccountBlock(from string, to string, amount int) error {
+	if _, err := common.ParseAddress(from); err != nil {
+		return err
+	}
+	// a wrong recipient would burn the amount
+	if _, err := common.ParseAddress(to); err != nil {
+		return err
+	}
+	return self.sendAccountBlock(from, to, amount, "")
+}
+
+func (self *ledger) RegisterAccountBlock(from string) error {
+	if _, err := common.ParseAddress(from); err != nil {
+		return err
+	}
+	return self.sendAccountBlock(from, consensus.RegisterAddress.String(), 0, "")
+}
+
+func (self *ledger) VoteAccountBlock(from string, candidate string) error {
+	if _, err := common.ParseAddress(from); err != nil {
+		return err
+	}
+	if _, err := common.ParseAddress(candidate); err != nil {
+		return err
+	}
+	return self.sendAccountBlock(from, consensus.VoteAddress.String(), 0, candidate)
+}
+
+func (self *ledger) sendAccountBlock(from string, to string, amount int, data string) error {
 	headAccount, _ := self.bc.HeadAccount(from)
 	headSnaphost, _ := self.bc.HeadSnapshot()
 
 	newBlock := common.NewAccountBlockFrom(headAccount, from, time.Now(), amount, headSnaphost,
 		common.SEND, from, to, "", -1)
+	newBlock.Data = data
 	newBlock.SetHash(tools.CalculateAccountHash(newBlock))
-	err := self.bpool.AddDirectAccountBlock(from, newBlock)
+	err := tools.SignBlock(newBlock, self.signerFn)
+	if err != nil {
+		return err
+	}
+	err = self.bpool.AddDirectAccountBlock(from, newBlock)
 	if err == nil {
 		self.syncer.Sender().BroadcastAccountBlocks(from, []*common.AccountStateBlock{newBlock})
 	}
 	return err
 }
 func (self *ledger) ResponseAccountBlock(from string, to string, reqHash string) error {
+	if _, err := common.ParseAddress(to); err != nil {
+		return err
+	}
 	b := self.bc.GetAccountByHash(from, reqHash)
 	if b == nil {
 		return errors.New("not exist for account[" + from + "]block[" + reqHash + "]")
@@ -120,8 +175,12 @@ func (self *ledger) ResponseAccountBlock(from string, to string, reqHash string)
 	modifiedAmount := -reqBlock.ModifiedAmount
 	block := common.NewAccountBlock(prevHeight+1, "", prevHash, to, time.Now(), prevAmount+modifiedAmount, modifiedAmount, snapshotBlock.Height(), snapshotBlock.Hash(), common.RECEIVED, from, to, reqHash, reqBlock.Height())
 	block.SetHash(tools.CalculateAccountHash(block))
+	err := tools.SignBlock(block, self.signerFn)
+	if err != nil {
+		return err
+	}
 
-	err := self.bpool.AddDirectAccountBlock(to, block)
+	err = self.bpool.AddDirectAccountBlock(to, block)
 	if err == nil {
 		self.syncer.Sender().BroadcastAccountBlocks(to, []*common.AccountStateBlock{block})
 	}
@@ -133,15 +192,24 @@ func NewLedger(bc chain.BlockChain) *ledger {
 	ledger.rwMutex = new(sync.RWMutex)
 	ledger.bc = bc
 	ledger.bpool = pool.NewPool(ledger.bc, ledger.rwMutex)
+	ledger.packing = chain.NewOldestFirstPolicy(common.MaxSnapshotAccounts)
 	return ledger
 }
 
-func (self *ledger) Init(syncer syncer.Syncer) {
+func (self *ledger) Init(syncer syncer.Syncer, cv consensus.ConsensusVerifier) {
 	self.syncer = syncer
 
-	self.bpool.Init(syncer.Fetcher())
+	self.bpool.Init(syncer.Fetcher(), syncer.Sender(), cv)
 	self.reqPool = newReqPool()
-	self.bc.SetChainListener(self.reqPool)
+	self.bc.AddChainListener(self.reqPool)
+}
+
+func (self *ledger) SetSignerFn(fn common.SignerFn) {
+	self.signerFn = fn
+}
+
+func (self *ledger) SetPackingPolicy(policy chain.PackingPolicy) {
+	self.packing = policy
 }
 
 func (self *ledger) ListRequest(address string) []*Req {
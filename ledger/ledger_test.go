@@ -7,6 +7,7 @@ import (
 
 	"github.com/asaskevich/EventBus"
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/common/log"
 	"github.com/viteshan/naive-vite/consensus"
@@ -30,6 +31,10 @@ func (self *TestSyncer) BroadcastSnapshotBlocks([]*common.SnapshotBlock) error {
 	return nil
 }
 
+func (self *TestSyncer) BroadcastEvidences([]*common.Evidence) error {
+	return nil
+}
+
 func (self *TestSyncer) SendAccountBlocks(string, []*common.AccountStateBlock, p2p.Peer) error {
 	panic("implement me")
 }
@@ -70,6 +75,10 @@ func (self *TestSyncer) Done() bool {
 	return true
 }
 
+func (self *TestSyncer) Behind() int {
+	return 0
+}
+
 func (self *TestSyncer) DefaultHandler() syncer.MsgHandler {
 	panic("implement me")
 }
@@ -303,7 +312,7 @@ func TestLedger_MiningSnapshotBlock(t *testing.T) {
 
 func genMiner(committee *consensus.Committee, rw miner.SnapshotChainRW, status face.SyncStatus) (miner.Miner, EventBus.Bus) {
 	bus := EventBus.New()
-	coinbase := common.HexToAddress("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")
+	coinbase := config.DevAddress(2)
 	miner := miner.NewMiner(rw, status, bus, coinbase, committee)
 	return miner, bus
 }
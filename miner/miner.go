@@ -1,11 +1,14 @@
 package miner
 
 import (
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/asaskevich/EventBus"
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/common/log"
 	"github.com/viteshan/naive-vite/consensus"
@@ -48,26 +51,39 @@ type Miner interface {
 	Init()
 	Start()
 	Stop()
+	// coinbases can be changed while mining, slots of a coinbase are subscribed at once
+	AddCoinbase(coinbase common.Address)
+	RemoveCoinbase(coinbase common.Address)
+	Coinbases() []common.Address
+	// slots are skipped while snapshot head is behind peers by more blocks, default is used if blocks <= 0
+	SetMaxBehind(blocks int)
 }
 
 type miner struct {
 	MinerLifecycle
-	chain      SnapshotChainRW
-	mining     int32
-	coinbase   common.Address // address
-	worker     *worker
-	consensus  consensus.Consensus
-	mem        *consensus.SubscribeMem
-	bus        EventBus.Bus
-	syncStatus face.SyncStatus
+	chain     SnapshotChainRW
+	mining    int32
+	worker    *worker
+	consensus consensus.Consensus
+	// key: coinbase, slots of all coinbases are notified to the same chan
+	mems        map[string]*consensus.SubscribeMem
+	notify      chan time.Time
+	subscribing bool
+	memMu       sync.Mutex
+	bus         EventBus.Bus
+	syncStatus  face.SyncStatus
 }
 
-func NewMiner(chain SnapshotChainRW, syncStatus face.SyncStatus, bus EventBus.Bus, coinbase common.Address, con consensus.Consensus) Miner {
-	miner := &miner{chain: chain, coinbase: coinbase}
+func NewMiner(chain SnapshotChainRW, syncStatus face.SyncStatus, bus EventBus.Bus, coinbases []common.Address, con consensus.Consensus, clk clock.Clock) Miner {
+	miner := &miner{chain: chain}
 
 	miner.consensus = con
-	miner.mem = &consensus.SubscribeMem{Mem: miner.coinbase, Notify: make(chan time.Time)}
-	miner.worker = &worker{chain: chain, workChan: miner.mem.Notify, coinbase: coinbase}
+	miner.notify = make(chan time.Time)
+	miner.mems = make(map[string]*consensus.SubscribeMem)
+	for _, coinbase := range coinbases {
+		miner.mems[coinbase.String()] = &consensus.SubscribeMem{Mem: coinbase, Notify: miner.notify}
+	}
+	miner.worker = &worker{chain: chain, workChan: miner.notify, owner: miner.owner, status: syncStatus, maxBehind: maxBehind, clock: clk}
 	miner.bus = bus
 	miner.syncStatus = syncStatus
 
@@ -79,7 +95,7 @@ func (self *miner) Init() {
 	self.worker.Init()
 	dwlDownFn := func() {
 		log.Info("sync success.")
-		self.consensus.Subscribe(self.mem)
+		self.subscribe()
 	}
 	self.bus.SubscribeOnce(common.DwlDone, dwlDownFn)
 }
@@ -89,7 +105,7 @@ func (self *miner) Start() {
 	defer self.PostStart()
 
 	if self.syncStatus.Done() {
-		self.consensus.Subscribe(self.mem)
+		self.subscribe()
 	}
 	self.worker.Start()
 }
@@ -99,7 +115,89 @@ func (self *miner) Stop() {
 	defer self.PostStop()
 
 	self.worker.Stop()
-	self.consensus.Subscribe(nil)
+	self.unsubscribe()
+}
+
+func (self *miner) AddCoinbase(coinbase common.Address) {
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	if _, ok := self.mems[coinbase.String()]; ok {
+		return
+	}
+	mem := &consensus.SubscribeMem{Mem: coinbase, Notify: self.notify}
+	self.mems[coinbase.String()] = mem
+	if self.subscribing {
+		self.consensus.Subscribe(mem)
+	}
+	log.Info("coinbase[%s] added.", coinbase)
+}
+
+func (self *miner) RemoveCoinbase(coinbase common.Address) {
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	if _, ok := self.mems[coinbase.String()]; !ok {
+		return
+	}
+	delete(self.mems, coinbase.String())
+	self.consensus.UnSubscribe(coinbase)
+	log.Info("coinbase[%s] removed.", coinbase)
+}
+
+func (self *miner) Coinbases() []common.Address {
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	var result []common.Address
+	for _, mem := range self.mems {
+		result = append(result, mem.Mem)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+func (self *miner) SetMaxBehind(blocks int) {
+	if blocks <= 0 {
+		blocks = maxBehind
+	}
+	self.worker.mu.Lock()
+	defer self.worker.mu.Unlock()
+	self.worker.maxBehind = blocks
+}
+
+func (self *miner) subscribe() {
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	self.subscribing = true
+	for _, mem := range self.mems {
+		self.consensus.Subscribe(mem)
+	}
+}
+
+func (self *miner) unsubscribe() {
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	self.subscribing = false
+	for _, mem := range self.mems {
+		self.consensus.UnSubscribe(mem.Mem)
+	}
+}
+
+// the coinbase whose slot starts at t
+func (self *miner) owner(t time.Time) (common.Address, bool) {
+	slots, err := self.consensus.Schedule(t, t.Add(time.Second))
+	if err != nil {
+		log.Error("get slot fail. time:%s, err:%v", t, err)
+		return common.Address{}, false
+	}
+	self.memMu.Lock()
+	defer self.memMu.Unlock()
+	for _, slot := range slots {
+		if _, ok := self.mems[slot.Member.String()]; ok && slot.STime.Equal(t) {
+			return slot.Member, true
+		}
+	}
+	return common.Address{}, false
 }
 
 func (self *miner) Destroy() {
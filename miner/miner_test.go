@@ -2,12 +2,14 @@ package miner
 
 import (
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/asaskevich/EventBus"
-	"github.com/viteshan/naive-vite/chain"
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/consensus"
 )
@@ -23,33 +25,38 @@ func (SnapshotRW) MiningSnapshotBlock(address string, timestamp int64) error {
 
 func genMiner(committee *consensus.Committee, status face.SyncStatus) (Miner, EventBus.Bus) {
 	bus := EventBus.New()
-	coinbase := common.HexToAddress("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")
+	coinbase := config.DevAddress(2)
 	rw := &SnapshotRW{}
-	miner := NewMiner(rw, status, bus, coinbase, committee)
+	miner := NewMiner(rw, status, bus, []common.Address{coinbase}, committee, clock.NewRealClock())
 	return miner, bus
 }
 
 func genMinerAuto(committee *consensus.Committee, status face.SyncStatus) (Miner, EventBus.Bus) {
 	bus := EventBus.New()
-	coinbase := common.HexToAddress("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")
+	coinbase := config.DevAddress(2)
 	rw := &SnapshotRW{}
-	miner := NewMiner(rw, status, bus, coinbase, committee)
+	miner := NewMiner(rw, status, bus, []common.Address{coinbase}, committee, clock.NewRealClock())
 	return miner, bus
 }
 
 func genCommitee() *consensus.Committee {
-	genesisTime := chain.GetGenesisSnapshot().Timestamp()
+	genesisTime := time.Unix(config.DefaultGenesis().Timestamp, 0)
 	committee := consensus.NewCommittee(genesisTime, 1, int32(len(consensus.DefaultMembers)))
 	return committee
 }
 
 type testSyncStatus struct {
+	behind int32
 }
 
 func (*testSyncStatus) Done() bool {
 	return true
 }
 
+func (self *testSyncStatus) Behind() int {
+	return int(atomic.LoadInt32(&self.behind))
+}
+
 func TestNewMiner(t *testing.T) {
 	committee := genCommitee()
 	miner, bus := genMiner(committee, &testSyncStatus{})
@@ -69,14 +76,165 @@ func TestNewMiner(t *testing.T) {
 	}
 	c <- 0
 }
+
+// record timestamps and coinbases of mined blocks
+type recordRW struct {
+	ch        chan int64
+	coinbases chan string
+}
+
+func (self *recordRW) MiningSnapshotBlock(address string, timestamp int64) error {
+	if self.coinbases != nil {
+		self.coinbases <- address
+	}
+	self.ch <- timestamp
+	return nil
+}
+
+func expectMined(t *testing.T, rw *recordRW, coinbase common.Address, ts time.Time) {
+	select {
+	case address := <-rw.coinbases:
+		if address != coinbase.String() {
+			t.Errorf("coinbase error. %s, expected %s", address, coinbase)
+		}
+		if mined := <-rw.ch; mined != ts.Unix() {
+			t.Errorf("mining time error. %d, expected %d", mined, ts.Unix())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s should mine at %d.", coinbase, ts.Unix())
+	}
+}
+
+func TestMinerSchedule(t *testing.T) {
+	genesisTime := time.Unix(config.DefaultGenesis().Timestamp, 0)
+	clk := clock.NewManualClock(genesisTime.Add(2 * time.Second))
+	committee := consensus.NewConsensus(genesisTime, consensus.DefaultMembers, nil, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	rw := &recordRW{ch: make(chan int64, 10)}
+	// slot of member 2 is the third second of every round
+	miner := NewMiner(rw, &testSyncStatus{}, EventBus.New(), []common.Address{config.DevAddress(2)}, committee, clk)
+
+	committee.Init()
+	miner.Init()
+	committee.Start()
+	miner.Start()
+	defer committee.Stop()
+	defer miner.Stop()
+
+	// the first round is skipped, round 1 starts at 5s
+	clk.BlockUntil(1)
+	clk.Add(3 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	select {
+	case ts := <-rw.ch:
+		if ts != genesisTime.Unix()+7 {
+			t.Errorf("mining time error. %d", ts-genesisTime.Unix())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("miner should mine in its slot.")
+	}
+	select {
+	case ts := <-rw.ch:
+		t.Errorf("unexpected mining. %d", ts-genesisTime.Unix())
+	default:
+	}
+}
+
+func TestMinerCoinbases(t *testing.T) {
+	genesisTime := time.Unix(config.DefaultGenesis().Timestamp, 0)
+	clk := clock.NewManualClock(genesisTime.Add(2 * time.Second))
+	committee := consensus.NewConsensus(genesisTime, consensus.DefaultMembers, nil, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	rw := &recordRW{ch: make(chan int64, 10), coinbases: make(chan string, 10)}
+	miner := NewMiner(rw, &testSyncStatus{}, EventBus.New(), []common.Address{config.DevAddress(1), config.DevAddress(3)}, committee, clk)
+
+	committee.Init()
+	miner.Init()
+	committee.Start()
+	miner.Start()
+	defer committee.Stop()
+	defer miner.Stop()
+
+	// round 1 starts at 5s, slots of member 1 and 3 are 6s and 8s
+	clk.BlockUntil(1)
+	clk.Add(4 * time.Second)
+	expectMined(t, rw, config.DevAddress(1), genesisTime.Add(6*time.Second))
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectMined(t, rw, config.DevAddress(3), genesisTime.Add(8*time.Second))
+
+	// round 2 starts at 10s, member 0 takes the place of member 3
+	miner.RemoveCoinbase(config.DevAddress(3))
+	miner.AddCoinbase(config.DevAddress(0))
+	if len(miner.Coinbases()) != 2 {
+		t.Fatalf("coinbases error. %v", miner.Coinbases())
+	}
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectMined(t, rw, config.DevAddress(0), genesisTime.Add(10*time.Second))
+	clk.BlockUntil(1)
+	clk.Add(time.Second)
+	expectMined(t, rw, config.DevAddress(1), genesisTime.Add(11*time.Second))
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	select {
+	case address := <-rw.coinbases:
+		t.Errorf("removed coinbase should not mine. %s", address)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMinerBehind(t *testing.T) {
+	genesisTime := time.Unix(config.DefaultGenesis().Timestamp, 0)
+	clk := clock.NewManualClock(genesisTime.Add(2 * time.Second))
+	committee := consensus.NewConsensus(genesisTime, consensus.DefaultMembers, nil, config.Consensus{Interval: 1, MemCnt: 5}, clk)
+	rw := &recordRW{ch: make(chan int64, 10), coinbases: make(chan string, 10)}
+	status := &testSyncStatus{behind: maxBehind + 1}
+	miner := NewMiner(rw, status, EventBus.New(), []common.Address{config.DevAddress(2)}, committee, clk)
+
+	committee.Init()
+	miner.Init()
+	committee.Start()
+	miner.Start()
+	defer committee.Stop()
+	defer miner.Stop()
+
+	// slot 7s is skipped, head is far behind peers
+	clk.BlockUntil(1)
+	clk.Add(3 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	clk.BlockUntil(1)
+	select {
+	case ts := <-rw.ch:
+		t.Fatalf("miner should not mine upon a stale head. %d", ts-genesisTime.Unix())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// caught up before slot 12s
+	atomic.StoreInt32(&status.behind, maxBehind)
+	clk.Add(3 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectMined(t, rw, config.DevAddress(2), genesisTime.Add(12*time.Second))
+
+	// configured to tolerate more blocks before slot 17s
+	atomic.StoreInt32(&status.behind, maxBehind+5)
+	miner.SetMaxBehind(maxBehind + 5)
+	clk.BlockUntil(1)
+	clk.Add(3 * time.Second)
+	clk.BlockUntil(1)
+	clk.Add(2 * time.Second)
+	expectMined(t, rw, config.DevAddress(2), genesisTime.Add(17*time.Second))
+}
+
 func TestVerifier(t *testing.T) {
 	committee := genCommitee()
 
-	coinbase := common.HexToAddress("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")
+	coinbase := config.DevAddress(2)
 
-	verify, _ := committee.Verify(SnapshotRW{}, common.NewSnapshotBlock(0, "", "", coinbase.String(), time.Unix(1532504321, 0), nil))
+	verify, _ := committee.Verify(nil, common.NewSnapshotBlock(0, "", "", coinbase.String(), time.Unix(1532504321, 0), nil))
 	println(verify)
-	verify2, _ := committee.Verify(SnapshotRW{}, common.NewSnapshotBlock(0, "", "", coinbase.String(), time.Unix(1532504320, 0), nil))
+	verify2, _ := committee.Verify(nil, common.NewSnapshotBlock(0, "", "", coinbase.String(), time.Unix(1532504320, 0), nil))
 	println(verify2)
 }
 
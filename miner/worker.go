@@ -1,21 +1,33 @@
 package miner
 
 import (
-	"github.com/viteshan/naive-vite/common"
-	"github.com/viteshan/naive-vite/common/log"
 	"sync"
 	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/face"
+	"github.com/viteshan/naive-vite/common/log"
 )
 
+// mining upon a snapshot head behind peers by more blocks only makes forks, default of config.Miner.MaxBehind
+const maxBehind = 10
+
 // worker
 type worker struct {
 	MinerLifecycle
 	workChan <-chan time.Time
 	chain    SnapshotChainRW
-	coinbase common.Address
-	mu       sync.Mutex
-	updateWg sync.WaitGroup
-	updateCh chan int // update goroutine closed event chan
+	// which coinbase the slot belongs to
+	owner  func(t time.Time) (common.Address, bool)
+	status face.SyncStatus
+	// skip slots if snapshot head is behind peers by more blocks
+	maxBehind int
+	stale     bool
+	clock     clock.Clock
+	mu        sync.Mutex
+	updateWg  sync.WaitGroup
+	updateCh  chan int // update goroutine closed event chan
 }
 
 func (self *worker) Init() {
@@ -49,7 +61,7 @@ func (self *worker) update(ch chan int) {
 			if !ok {
 				log.Warn("channel closed.")
 				if !self.Stopped() {
-					time.Sleep(time.Second)
+					self.clock.Sleep(time.Second)
 				}
 			} else {
 				log.Info("start working once.")
@@ -65,7 +77,21 @@ func (self *worker) update(ch chan int) {
 func (self *worker) genAndInsert(t time.Time) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
-	self.chain.MiningSnapshotBlock(self.coinbase.String(), t.Unix())
+	if behind := self.status.Behind(); behind > self.maxBehind {
+		self.stale = true
+		log.Warn("snapshot head is %d blocks behind peers, skip mining. time:%s", behind, t)
+		return
+	}
+	if self.stale {
+		self.stale = false
+		log.Info("snapshot head caught up with peers, resume mining.")
+	}
+	coinbase, ok := self.owner(t)
+	if !ok {
+		log.Warn("no coinbase owns the slot. time:%s", t)
+		return
+	}
+	self.chain.MiningSnapshotBlock(coinbase.String(), t.Unix())
 }
 
 func (self *worker) setWorkCh(newWorkCh <-chan time.Time) {
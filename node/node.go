@@ -1,16 +1,21 @@
 package node
 
 import (
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/asaskevich/EventBus"
 	"github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
 	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/log"
 	"github.com/viteshan/naive-vite/consensus"
 	"github.com/viteshan/naive-vite/ledger"
 	"github.com/viteshan/naive-vite/miner"
 	"github.com/viteshan/naive-vite/p2p"
+	"github.com/viteshan/naive-vite/pool"
 	"github.com/viteshan/naive-vite/syncer"
 	"github.com/viteshan/naive-vite/wallet"
 )
@@ -21,9 +26,18 @@ type Node interface {
 	Stop()
 	StartMiner()
 	StopMiner()
+	// snapshot blocks are mined for every coinbase, coinbase must be unlocked in wallet
+	AddCoinbase(address string) error
+	RemoveCoinbase(address string) error
+	Coinbases() []string
+	// forks and orphan blocks of the account pool, snapshot pool if address is empty
+	PoolInfo(address string) (*pool.Report, error)
+	// fork tree of the pool in graphviz dot
+	PoolDot(address string) (string, error)
 	Leger() ledger.Ledger
 	P2P() p2p.P2P
 	Wallet() wallet.Wallet
+	Consensus() consensus.Consensus
 }
 
 func NewNode(cfg config.Node) Node {
@@ -31,17 +45,30 @@ func NewNode(cfg config.Node) Node {
 	self.bus = EventBus.New()
 	self.closed = make(chan struct{})
 	self.cfg = cfg
+	self.clock = clock.NewRealClock()
+	genesis, err := self.cfg.Genesis()
+	if err != nil {
+		panic("load genesis fail. file:" + self.cfg.GenesisFile + ", err:" + err.Error())
+	}
+	// network is defined by genesis
+	self.cfg.P2pCfg.NetId = genesis.NetId
+	if self.cfg.ConsensusCfg.MemCnt == 0 {
+		self.cfg.ConsensusCfg.MemCnt = len(genesis.Producers)
+	}
 	self.p2p = p2p.NewP2P(self.cfg.P2pCfg)
 	self.syncer = syncer.NewSyncer(self.p2p, self.bus)
-	self.bc = chain.NewChain()
+	self.bc = chain.NewChain(self.cfg.DataDir, genesis)
 	self.ledger = ledger.NewLedger(self.bc)
-	self.consensus = consensus.NewConsensus(chain.GetGenesisSnapshot().Timestamp(), self.cfg.ConsensusCfg)
+	self.ledger.Pool().SetCaps(self.cfg.PoolCfg)
+	self.consensus = consensus.NewConsensus(time.Unix(genesis.Timestamp, 0), genesis.Producers, self.bc, self.cfg.ConsensusCfg, self.clock)
 
 	if self.cfg.MinerCfg.Enabled {
-		if self.cfg.MinerCfg.CoinBase().String() == "" {
-			log.Error("coinBase must be set.")
+		coinbases, err := self.cfg.MinerCfg.CoinBases()
+		if err != nil {
+			log.Error("coinBase must be set. err:%v", err)
 		} else {
-			self.miner = miner.NewMiner(self.ledger, self.syncer, self.bus, self.cfg.MinerCfg.CoinBase(), self.consensus)
+			self.miner = miner.NewMiner(self.ledger, self.syncer, self.bus, coinbases, self.consensus, self.clock)
+			self.miner.SetMaxBehind(self.cfg.MinerCfg.MaxBehind)
 		}
 	}
 	return self
@@ -56,6 +83,7 @@ type node struct {
 	miner     miner.Miner
 	wallet    wallet.Wallet
 	bus       EventBus.Bus
+	clock     clock.Clock
 
 	cfg    config.Node
 	closed chan struct{}
@@ -63,14 +91,33 @@ type node struct {
 }
 
 func (self *node) Init() {
+	keyDir := ""
+	if self.cfg.DataDir != "" {
+		keyDir = filepath.Join(self.cfg.DataDir, "keystore")
+	}
+	w, err := wallet.NewWallet(keyDir)
+	if err != nil {
+		panic("open wallet fail. dir:" + keyDir + ", err:" + err.Error())
+	}
+	self.wallet = w
 	self.syncer.Init(self.ledger.Chain(), self.ledger.Pool())
-	self.ledger.Init(self.syncer)
+	self.ledger.Init(self.syncer, self.consensus)
+	self.ledger.SetSignerFn(self.wallet.Sign)
 	self.consensus.Init()
+	self.bc.AddChainListener(self.consensus)
+	self.bc.SetCommittee(self.consensus)
 	self.p2p.Init()
 	if self.miner != nil {
+		// snapshot blocks are signed by coinbase
+		// coinbase must be unlocked before mining
+		if self.cfg.MinerCfg.HexCoinbase != "" {
+			err := self.wallet.SetCoinBase(self.cfg.MinerCfg.HexCoinbase)
+			if err != nil {
+				log.Error("coinBase can't sign blocks. err:%v", err)
+			}
+		}
 		self.miner.Init()
 	}
-	self.wallet = wallet.NewWallet()
 }
 
 func (self *node) Start() {
@@ -97,13 +144,25 @@ func (self *node) Stop() {
 	self.ledger.Stop()
 	self.p2p.Stop()
 	self.wg.Wait()
+	if err := self.bc.Close(); err != nil {
+		log.Error("close chain fail. err:%v", err)
+	}
 	log.Info("node stopped...")
 }
 
 func (self *node) StartMiner() {
 	if self.miner == nil {
-		self.cfg.MinerCfg.HexCoinbase = self.wallet.CoinBase()
-		self.miner = miner.NewMiner(self.ledger, self.syncer, self.bus, self.cfg.MinerCfg.CoinBase(), self.consensus)
+		// coinbase of wallet mines if no coinbase is configured
+		if self.cfg.MinerCfg.HexCoinbase == "" && len(self.cfg.MinerCfg.HexCoinbases) == 0 {
+			self.cfg.MinerCfg.HexCoinbase = self.wallet.CoinBase()
+		}
+		coinbases, err := self.cfg.MinerCfg.CoinBases()
+		if err != nil {
+			log.Error("coinBase is invalid. err:%v", err)
+			return
+		}
+		self.miner = miner.NewMiner(self.ledger, self.syncer, self.bus, coinbases, self.consensus, self.clock)
+		self.miner.SetMaxBehind(self.cfg.MinerCfg.MaxBehind)
 		self.miner.Init()
 	}
 	self.miner.Start()
@@ -117,6 +176,83 @@ func (self *node) StopMiner() {
 	}
 }
 
+func (self *node) AddCoinbase(address string) error {
+	coinbase, err := common.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	// can sign with it
+	if _, _, err := self.wallet.Sign(coinbase, []byte(address)); err != nil {
+		return err
+	}
+	if self.miner != nil {
+		self.miner.AddCoinbase(coinbase)
+		return nil
+	}
+	for _, c := range self.Coinbases() {
+		if c == coinbase.String() {
+			return nil
+		}
+	}
+	self.cfg.MinerCfg.HexCoinbases = append(self.cfg.MinerCfg.HexCoinbases, coinbase.String())
+	return nil
+}
+
+func (self *node) RemoveCoinbase(address string) error {
+	coinbase, err := common.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	if self.miner != nil {
+		self.miner.RemoveCoinbase(coinbase)
+		return nil
+	}
+	same := func(hex string) bool {
+		addr, err := common.ParseAddress(hex)
+		return err == nil && addr == coinbase
+	}
+	// primary coinbase is one of the set
+	if same(self.cfg.MinerCfg.HexCoinbase) {
+		self.cfg.MinerCfg.HexCoinbase = ""
+	}
+	var rest []string
+	for _, c := range self.cfg.MinerCfg.HexCoinbases {
+		if !same(c) {
+			rest = append(rest, c)
+		}
+	}
+	self.cfg.MinerCfg.HexCoinbases = rest
+	return nil
+}
+
+// primary coinbase and the others, the same set the miner is started with
+func (self *node) Coinbases() []string {
+	var result []string
+	if self.miner != nil {
+		for _, c := range self.miner.Coinbases() {
+			result = append(result, c.String())
+		}
+		return result
+	}
+	coinbases, _ := self.cfg.MinerCfg.CoinBases()
+	for _, c := range coinbases {
+		result = append(result, c.String())
+	}
+	return result
+}
+
+func (self *node) PoolInfo(address string) (*pool.Report, error) {
+	return self.ledger.Pool().Info(address)
+}
+
+func (self *node) PoolDot(address string) (string, error) {
+	report, err := self.ledger.Pool().Info(address)
+	if err != nil {
+		return "", err
+	}
+	return report.Dot(), nil
+}
+
 func (self *node) Leger() ledger.Ledger {
 	return self.ledger
 }
@@ -124,6 +260,9 @@ func (self *node) Leger() ledger.Ledger {
 func (self *node) Wallet() wallet.Wallet {
 	return self.wallet
 }
+func (self *node) Consensus() consensus.Consensus {
+	return self.consensus
+}
 func (self *node) P2P() p2p.P2P {
 	return self.p2p
 }
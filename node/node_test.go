@@ -1,6 +1,7 @@
 package node
 
 import (
+	"crypto/ed25519"
 	"net/http"
 	"strconv"
 	"testing"
@@ -82,25 +83,27 @@ func TestSendReceived(t *testing.T) {
 	boot := startBoot(defaultBoot)
 	n := startNode(defaultBoot, 8091, "1")
 	time.Sleep(time.Second)
-	balance := n.Leger().GetAccountBalance("jie")
+	jie := unlockedAccount(n, config.DevKey(0))
+	jie2 := unlockedAccount(n, nil)
+	balance := n.Leger().GetAccountBalance(jie)
 	if balance != 200 {
 		t.Error("balance is wrong.", balance, 200)
 	}
-	err := n.Leger().RequestAccountBlock("jie", "jie2", -20)
+	err := n.Leger().RequestAccountBlock(jie, jie2, -20)
 	if err != nil {
 		t.Error("send tx error.", err)
 	}
-	balance = n.Leger().GetAccountBalance("jie")
+	balance = n.Leger().GetAccountBalance(jie)
 	if balance != 180 {
 		t.Error("balance is wrong.", balance, 180)
 	}
-	reqs := n.Leger().ListRequest("jie2")
+	reqs := n.Leger().ListRequest(jie2)
 	if len(reqs) != 1 {
 		t.Error("reqs size is wrong.", reqs)
 		return
 	}
 	req := reqs[0]
-	err = n.Leger().ResponseAccountBlock("jie", "jie2", req.ReqHash)
+	err = n.Leger().ResponseAccountBlock(jie, jie2, req.ReqHash)
 	if err != nil {
 		t.Error("response error.", err, req.ReqHash)
 	}
@@ -109,31 +112,27 @@ func TestSendReceived(t *testing.T) {
 }
 
 func TestMuilt(t *testing.T) {
-	//  "vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8",
-	//	"vite_1cb2ab2738cd913654658e879bef8115eb1aa61a9be9d15c3a",
-	//	"vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8",
-	//	"vite_85e8adb768aed85f2445eb1d71b933370d2980916baa3c1f3c",
-	//	"vite_93dd41694edd756512da7c4af429f3e875c374a53bfd217e00",
+	// producers of default genesis
 	defaultBoot := "localhost:8000"
 	//boot := startBoot(defaultBoot)
 	n := startNode(defaultBoot, 8081, "1")
-	n.Wallet().SetCoinBase("vite_2ad661b3b5fa90af7703936ba36f8093ef4260aaaeb5f15cf8")
+	n.Wallet().SetCoinBase(unlockedAccount(n, config.DevKey(0)))
 	n.StartMiner()
 
 	n = startNode(defaultBoot, 8082, "2")
-	n.Wallet().SetCoinBase("vite_1cb2ab2738cd913654658e879bef8115eb1aa61a9be9d15c3a")
+	n.Wallet().SetCoinBase(unlockedAccount(n, config.DevKey(1)))
 	n.StartMiner()
 
 	n = startNode(defaultBoot, 8083, "3")
-	n.Wallet().SetCoinBase("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")
+	n.Wallet().SetCoinBase(unlockedAccount(n, config.DevKey(2)))
 	n.StartMiner()
 
 	n = startNode(defaultBoot, 8084, "4")
-	n.Wallet().SetCoinBase("vite_85e8adb768aed85f2445eb1d71b933370d2980916baa3c1f3c")
+	n.Wallet().SetCoinBase(unlockedAccount(n, config.DevKey(3)))
 	n.StartMiner()
 
 	n = startNode(defaultBoot, 8085, "5")
-	n.Wallet().SetCoinBase("vite_93dd41694edd756512da7c4af429f3e875c374a53bfd217e00")
+	n.Wallet().SetCoinBase(unlockedAccount(n, config.DevKey(4)))
 	n.StartMiner()
 
 	i := make(chan struct{})
@@ -162,27 +161,29 @@ func TestBenchmark(t *testing.T) {
 	n := startNode(defaultBoot, 8081, "100")
 	time.Sleep(time.Second)
 
-	balance := n.Leger().GetAccountBalance("jie")
+	jie := unlockedAccount(n, config.DevKey(0))
+	viteshan := unlockedAccount(n, config.DevKey(1))
+	balance := n.Leger().GetAccountBalance(jie)
 	if balance != 200 {
 		t.Error("balance is wrong.", balance, 200)
 	}
 
-	balance = n.Leger().GetAccountBalance("viteshan")
+	balance = n.Leger().GetAccountBalance(viteshan)
 	if balance != 200 {
 		t.Error("balance is wrong.", balance, 200)
 	}
 	N := 4
+	addrs := make([]string, N)
 	for i := 0; i < N; i++ {
-		addr := "jie" + strconv.Itoa(i)
-		err := n.Leger().RequestAccountBlock("jie", addr, -30)
+		addrs[i] = unlockedAccount(n, nil)
+		err := n.Leger().RequestAccountBlock(jie, addrs[i], -30)
 		if err != nil {
 			log.Error("%v", err)
 			return
 		}
 	}
 	for i := 0; i < N; i++ {
-		addr := "jie" + strconv.Itoa(i)
-		err := n.Leger().RequestAccountBlock("viteshan", addr, -30)
+		err := n.Leger().RequestAccountBlock(viteshan, addrs[i], -30)
 		if err != nil {
 			log.Error("%v", err)
 			return
@@ -190,11 +191,8 @@ func TestBenchmark(t *testing.T) {
 	}
 
 	for i := 0; i < N; i++ {
-		from := "jie" + strconv.Itoa(i)
-		to := "jie" + strconv.Itoa(i-1)
-		if i == 0 {
-			to = "jie" + strconv.Itoa(N-1)
-		}
+		from := addrs[i]
+		to := addrs[(i+N-1)%N]
 		go func(from string, to string) {
 			for {
 				balance := n.Leger().GetAccountBalance(from)
@@ -232,7 +230,7 @@ func TestBenchmark(t *testing.T) {
 				time.Sleep(time.Second)
 			}
 
-		}("jie" + strconv.Itoa(i))
+		}(addrs[i])
 	}
 
 	i := make(chan struct{})
@@ -251,3 +249,43 @@ func startNode(bootAddr string, port int, nodeId string) Node {
 	n.Start()
 	return n
 }
+
+// import key (create one if nil) and unlock it
+func unlockedAccount(n Node, key ed25519.PrivateKey) string {
+	var addr string
+	var err error
+	if key == nil {
+		addr, err = n.Wallet().CreateAccount("")
+	} else {
+		addr, err = n.Wallet().ImportKey(key, "")
+	}
+	if err == nil {
+		err = n.Wallet().Unlock(addr, "", 0)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func TestCoinbases(t *testing.T) {
+	dev0, dev1 := config.DevAddress(0).String(), config.DevAddress(1).String()
+	n := NewNode(config.Node{
+		P2pCfg:       config.P2P{NodeId: "1", Port: 8092},
+		ConsensusCfg: config.Consensus{Interval: 1},
+		MinerCfg:     config.Miner{HexCoinbase: dev0, HexCoinbases: []string{dev1, dev0}},
+	})
+	if cs := n.Coinbases(); len(cs) != 2 || cs[0] != dev0 || cs[1] != dev1 {
+		t.Fatalf("primary coinbase should be listed with others. %v", cs)
+	}
+	if err := n.RemoveCoinbase(dev0); err != nil {
+		t.Fatal(err)
+	}
+	if cs := n.Coinbases(); len(cs) != 1 || cs[0] != dev1 {
+		t.Errorf("primary coinbase should be removed. %v", cs)
+	}
+	n.RemoveCoinbase(dev1)
+	if cs := n.Coinbases(); len(cs) != 0 {
+		t.Errorf("all coinbases should be removed. %v", cs)
+	}
+}
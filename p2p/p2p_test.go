@@ -66,7 +66,7 @@ func peerFull(self string, peers []Peer, N int) bool {
 }
 
 func TestNewMsg(t *testing.T) {
-	msg := &Msg{T: common.State, Data: common.HexToAddress("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")}
+	msg := &Msg{T: common.State, Data: []byte("vite_2ad1b8f936f015fc80a2a5857dffb84b39f7675ab69ae31fc8")}
 	bytes, _ := json.Marshal(msg)
 
 	println(string(bytes))
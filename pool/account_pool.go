@@ -98,26 +98,6 @@ func (self *accountPool) FindRollbackPointForAccountHashH(height int, hash strin
 	return true, forkPoint.(*common.AccountStateBlock), chain, nil
 }
 
-func (self *accountPool) loop() int {
-	//if !self.compactLock.TryLock() {
-	//	return 0
-	//} else {
-	//	defer self.compactLock.UnLock()
-	//}
-	//
-	//now := time.Now()
-	//if now.After(self.loopTime.Add(time.Millisecond * 200)) {
-	//	self.loopTime = now
-	//	sum := 0
-	//	sum = sum + self.loopGenSnippetChains()
-	//	sum = sum + self.loopAppendChains()
-	//	sum = sum + self.loopFetchForSnippets()
-	//	sum = sum + self.TryInsert()
-	//	return sum
-	//}
-	return 0
-}
-
 /**
 1. compact for data
 	1.1. free blocks
@@ -136,18 +116,35 @@ func (self *accountPool) Compact() int {
 		defer self.compactLock.UnLock()
 	}
 
-	//	this is a rate limiter
-	now := time.Now()
-	if now.After(self.loopTime.Add(time.Millisecond * 200)) {
-		defer monitor.LogTime("pool", "accountCompact", now)
-		self.loopTime = now
-		sum := 0
-		sum = sum + self.loopGenSnippetChains()
-		sum = sum + self.loopAppendChains()
-		sum = sum + self.loopFetchForSnippets()
-		return sum
+	defer monitor.LogTime("pool", "accountCompact", time.Now())
+	sum := 0
+	sum = sum + self.loopGenSnippetChains()
+	sum = sum + self.loopAppendChains()
+	sum = sum + self.loopFetchForSnippets()
+	return sum
+}
+
+// fetch missing blocks of snippet chains again, return true if blocks are waiting in pool.
+// pool being worked on is skipped.
+func (self *accountPool) RetryFetch() bool {
+	if !self.compactLock.TryLock() {
+		return false
+	} else {
+		defer self.compactLock.UnLock()
+	}
+	if !self.busy() {
+		return false
 	}
-	return 0
+	self.loopFetchForSnippets()
+	return true
+}
+
+// must be called with compactLock locked
+func (self *accountPool) busy() bool {
+	pendingMu.Lock()
+	free := len(self.blockpool.freeBlocks)
+	pendingMu.Unlock()
+	return free > 0 || len(self.chainpool.snippetChains) > 0 || self.chainpool.current.size() > 0
 }
 
 /**
@@ -7,7 +7,6 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/viteshan/naive-vite/common"
 	"github.com/viteshan/naive-vite/common/face"
@@ -50,11 +49,15 @@ type BCPool struct {
 	version  *version.Version
 	verifier verifier.Verifier
 	rMu      sync.Mutex // direct add and loop insert
+	caps     poolCaps
+	// removed from pool, blocks are not added anymore. guarded by pendingMu
+	evicted bool
 }
 
 type blockPool struct {
 	freeBlocks     map[string]*PoolBlock // free state
 	compoundBlocks map[string]*PoolBlock // compound state
+	seq            uint64                // blocks added
 }
 type chainPool struct {
 	poolId          string
@@ -192,6 +195,19 @@ func (self *snippetChain) remTail() *PoolBlock {
 	return newTail
 }
 
+// remove the highest block
+func (self *snippetChain) remHead() *PoolBlock {
+	head := self.heightBlocks[self.headHeight]
+	delete(self.heightBlocks, self.headHeight)
+	self.headHeight--
+	if self.headHeight > self.tailHeight {
+		self.headHash = self.heightBlocks[self.headHeight].block.Hash()
+	} else {
+		self.headHash = self.tailHash
+	}
+	return head
+}
+
 func (self *snippetChain) merge(snippet *snippetChain) {
 	self.tailHeight = snippet.tailHeight
 	self.tailHash = snippet.tailHash
@@ -206,6 +222,8 @@ type forkedChain struct {
 	headHash   string
 	tailHash   string
 	referChain heightChainReader
+	// refused by checkReorg
+	refused bool
 }
 
 func (self *forkedChain) getBlock(height int, refer bool) *PoolBlock {
@@ -678,6 +696,7 @@ type PoolBlock struct {
 	block       common.Block
 	forkVersion int
 	v           *version.Version
+	seq         uint64 // arrival order
 }
 
 func (self *PoolBlock) checkForkVersion() bool {
@@ -702,17 +721,26 @@ func (self *blockPool) compound(w *PoolBlock) {
 	delete(self.freeBlocks, w.block.Hash())
 }
 
-func (self *BCPool) AddBlock(block common.Block) {
+// return false if pool is evicted, block should be added to the new pool of chain
+func (self *BCPool) AddBlock(block common.Block) bool {
 	wrapper := &PoolBlock{block: block, forkVersion: self.version.Val(), v: self.version}
 	pendingMu.Lock()
 	defer pendingMu.Unlock()
+	if self.evicted {
+		return false
+	}
 	hash := block.Hash()
 	height := block.Height()
 	if !self.blockpool.contains(hash, height) {
-		self.blockpool.putBlock(hash, wrapper)
+		self.blockpool.seq++
+		wrapper.seq = self.blockpool.seq
+		if self.admit(wrapper) {
+			self.blockpool.putBlock(hash, wrapper)
+		}
 	} else {
 		log.Warn("block exists in BCPool. hash:[%s], height:[%d].", hash, height)
 	}
+	return true
 }
 
 type ByHeight []*PoolBlock
@@ -765,6 +793,7 @@ func (self *BCPool) loopGenSnippetChains() int {
 		final[v.id()] = v
 	}
 	self.chainpool.snippetChains = final
+	self.evictSnippets()
 	return i
 }
 func (self *BCPool) AddDirectBlock(block common.Block) error {
@@ -923,6 +952,10 @@ func (self *BCPool) currentModify(forkHeight int, forkHash string) error {
 	if err != nil {
 		return errors.New("can't find fork point.")
 	}
+	err = self.checkReorg(finalChain.id(), forkBlock.Height())
+	if err != nil {
+		return err
+	}
 
 	//self.chainpool.getSendBlock(forkBlock.Height())
 
@@ -957,6 +990,15 @@ func (self *BCPool) CurrentChain() Chain {
 	return self.chainpool.current
 }
 
+// all forked chains, current chain included
+func (self *BCPool) Chains() []Chain {
+	var result []Chain
+	for _, c := range self.chainpool.chains {
+		result = append(result, c)
+	}
+	return result
+}
+
 // keyPoint, forkPoint, err
 func (self *BCPool) getForkPointByChains(chain1 Chain, chain2 Chain) (common.Block, common.Block, error) {
 	if chain1.Head().Height() > chain2.Head().Height() {
@@ -968,9 +1010,11 @@ func (self *BCPool) getForkPointByChains(chain1 Chain, chain2 Chain) (common.Blo
 
 // keyPoint, forkPoint, err
 func (self *BCPool) getForkPoint(longest Chain, current Chain) (common.Block, common.Block, error) {
-	curHeadHeight := current.HeadHeight()
-
-	i := curHeadHeight
+	// chain chosen by fork choice may be lower than current chain
+	i := current.HeadHeight()
+	if longest.HeadHeight() < i {
+		i = longest.HeadHeight()
+	}
 	var forkedBlock common.Block
 
 	for {
@@ -996,16 +1040,6 @@ func (self *BCPool) getForkPoint(longest Chain, current Chain) (common.Block, co
 	return nil, nil, errors.New("can't find fork point")
 }
 
-func (self *BCPool) loop() {
-	for {
-		self.loopGenSnippetChains()
-		self.loopAppendChains()
-		self.loopFetchForSnippets()
-		//self.CheckCurrentInsert()
-		time.Sleep(time.Second)
-	}
-}
-
 func (self *BCPool) ExistInCurrent(request face.FetchRequest) bool {
 	_, ok := self.chainpool.current.heightBlocks[request.Height]
 	return ok
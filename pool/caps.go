@@ -0,0 +1,169 @@
+package pool
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/log"
+	"github.com/viteshan/naive-vite/monitor"
+)
+
+// caps of orphan blocks of a BCPool, zero means no limit.
+// orphans are free blocks and blocks of snippet chains, the farthest from chain head are evicted first,
+// the oldest of the same height.
+type poolCaps struct {
+	maxBlocks     int
+	maxSnippetLen int
+	maxDistance   int
+	// blocks of chain rolled back by a fork
+	maxReorgDepth int
+}
+
+// check block before it's put to free blocks, return false if it's dropped.
+// must be called with pendingMu locked.
+func (self *BCPool) admit(w *PoolBlock) bool {
+	block := w.block
+	if self.caps.maxDistance > 0 {
+		head := self.chainpool.diskChain.Head()
+		if head != nil && block.Height() > head.Height()+self.caps.maxDistance {
+			monitor.LogEvent("pool", "evictFar")
+			log.Warn("block is too far from head, drop it. pool:%s, height:%d, head:%d.", self.Id, block.Height(), head.Height())
+			return false
+		}
+	}
+	free := self.blockpool.freeBlocks
+	if self.caps.maxBlocks <= 0 || len(free) < self.caps.maxBlocks {
+		return true
+	}
+	var victim *PoolBlock
+	for _, v := range free {
+		if victim == nil || farther(v, victim) {
+			victim = v
+		}
+	}
+	if block.Height() > victim.block.Height() {
+		monitor.LogEvent("pool", "evictFull")
+		return false
+	}
+	delete(free, victim.block.Hash())
+	monitor.LogEvent("pool", "evictFull")
+	return true
+}
+
+// a is evicted before b
+func farther(a *PoolBlock, b *PoolBlock) bool {
+	if a.block.Height() != b.block.Height() {
+		return a.block.Height() > b.block.Height()
+	}
+	return a.seq < b.seq
+}
+
+// trim snippet chains after free blocks are compounded to them
+func (self *BCPool) evictSnippets() {
+	snippets := self.chainpool.snippetChains
+	var evicted []*PoolBlock
+	total := 0
+	for _, s := range snippets {
+		for self.caps.maxSnippetLen > 0 && s.size() > self.caps.maxSnippetLen {
+			evicted = append(evicted, s.remHead())
+			monitor.LogEvent("pool", "evictSnippet")
+		}
+		total += s.size()
+	}
+	for self.caps.maxBlocks > 0 && total > self.caps.maxBlocks {
+		var victim *snippetChain
+		for _, s := range snippets {
+			if s.size() == 0 {
+				continue
+			}
+			if victim == nil || farther(s.heightBlocks[s.headHeight], victim.heightBlocks[victim.headHeight]) {
+				victim = s
+			}
+		}
+		evicted = append(evicted, victim.remHead())
+		monitor.LogEvent("pool", "evictFull")
+		if victim.size() == 0 {
+			delete(snippets, victim.id())
+		}
+		total--
+	}
+	if len(evicted) > 0 {
+		log.Warn("orphan blocks evicted. pool:%s, cnt:%d.", self.Id, len(evicted))
+	}
+	self.blockpool.forget(evicted, self.chainpool.diskChain.Head(), self.caps.maxDistance)
+}
+
+// evicted blocks can be received again.
+// blocks far below chain head are not remembered any more.
+func (self *blockPool) forget(evicted []*PoolBlock, head common.Block, distance int) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	for _, w := range evicted {
+		delete(self.compoundBlocks, w.block.Hash())
+	}
+	if head == nil || distance <= 0 {
+		return
+	}
+	for k, w := range self.compoundBlocks {
+		if w.block.Height() < head.Height()-distance {
+			delete(self.compoundBlocks, k)
+		}
+	}
+}
+
+// a fork rolling back more blocks than maxReorgDepth is refused, the forked chain is flagged and never chosen again.
+// the flag goes with the chain when it's removed from pool.
+func (self *BCPool) checkReorg(chainId string, forkHeight int) error {
+	head := self.chainpool.diskChain.Head()
+	if self.caps.maxReorgDepth <= 0 || head == nil || head.Height()-forkHeight <= self.caps.maxReorgDepth {
+		return nil
+	}
+	self.refuse(chainId)
+	monitor.LogEvent("pool", "reorgRefused")
+	return errors.New("reorg is too deep. pool:" + self.Id + ", chain:" + chainId +
+		", depth:" + strconv.Itoa(head.Height()-forkHeight) + ", max:" + strconv.Itoa(self.caps.maxReorgDepth))
+}
+
+func (self *BCPool) refuse(chainId string) {
+	if c, ok := self.chainpool.chains[chainId]; ok {
+		c.refused = true
+	}
+}
+
+// forked chains which are not refused
+func (self *BCPool) acceptableChains() []Chain {
+	var result []Chain
+	for _, c := range self.chainpool.chains {
+		if !c.refused {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// mark pool evicted if no blocks are above disk chain and no one is working on it,
+// orphan blocks are dropped with it if orphans is true.
+func (self *accountPool) evict(orphans bool) bool {
+	if !self.compactLock.TryLock() {
+		return false
+	}
+	defer self.compactLock.UnLock()
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	for _, c := range self.chainpool.chains {
+		if c.size() > 0 {
+			return false
+		}
+	}
+	free := len(self.blockpool.freeBlocks) + len(self.blockpool.compoundBlocks) + len(self.chainpool.snippetChains)
+	if free > 0 && !orphans {
+		return false
+	}
+	self.evicted = true
+	monitor.LogEvent("pool", "evictPool")
+	if free > 0 {
+		log.Warn("account pool is evicted with orphan blocks. pool:%s.", self.Id)
+	}
+	return true
+}
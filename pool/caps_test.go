@@ -0,0 +1,126 @@
+package pool
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/test"
+	"github.com/viteshan/naive-vite/version"
+)
+
+// chain with genesis only
+type genesisRw struct {
+}
+
+func (self *genesisRw) insertChain(block common.Block, forkVersion int) error {
+	return nil
+}
+
+func (self *genesisRw) removeChain(block common.Block) error {
+	return nil
+}
+
+func (self *genesisRw) head() common.Block {
+	return genesis
+}
+
+func (self *genesisRw) getBlock(height int) common.Block {
+	if height == 0 {
+		return genesis
+	}
+	return nil
+}
+
+func snippetBlocks(p *BCPool) map[int]bool {
+	result := make(map[int]bool)
+	for _, s := range p.chainpool.snippetChains {
+		for h := range s.heightBlocks {
+			result[h] = true
+		}
+	}
+	return result
+}
+
+func TestPoolCaps(t *testing.T) {
+	p := newBlockChainPool("bcPool-caps")
+	p.version = &version.Version{}
+	p.caps = poolCaps{maxBlocks: 10, maxSnippetLen: 4, maxDistance: 50}
+	p.init(&genesisRw{}, nil, NewFetcher("", &nopFetcher{}))
+
+	p.AddBlock(&test.TestBlock{Thash: "far", Theight: 51, TpreHash: "x", Tsigner: signer})
+	if len(p.blockpool.freeBlocks) != 0 {
+		t.Fatal("block too far from head should be dropped.")
+	}
+
+	// a linked snippet of 11-18 keeps its lowest blocks
+	for _, b := range genLinkBlock("A-", 11, 19, &test.TestBlock{Thash: "A-10", Theight: 10}) {
+		p.AddBlock(b)
+	}
+	p.loopGenSnippetChains()
+	blocks := snippetBlocks(p)
+	if len(blocks) != 4 || !blocks[11] || !blocks[14] {
+		t.Fatalf("snippet should be cut to its lowest blocks. %v", blocks)
+	}
+
+	// unlinked blocks of 20-39, the highest are dropped
+	for i := 20; i < 40; i++ {
+		p.AddBlock(&test.TestBlock{Thash: "B-" + strconv.Itoa(i), Theight: i, TpreHash: "x-" + strconv.Itoa(i), Tsigner: signer})
+	}
+	if len(p.blockpool.freeBlocks) != 10 {
+		t.Fatalf("free blocks should be capped. %d", len(p.blockpool.freeBlocks))
+	}
+	p.loopGenSnippetChains()
+	blocks = snippetBlocks(p)
+	if len(blocks) != 10 || !blocks[11] || !blocks[25] || blocks[26] {
+		t.Fatalf("orphans should be capped, the farthest are evicted. %v", blocks)
+	}
+
+	// evicted blocks can be received again
+	p.AddBlock(genLinkBlock("A-", 11, 19, &test.TestBlock{Thash: "A-10", Theight: 10})["A-15"])
+	if len(p.blockpool.freeBlocks) != 1 {
+		t.Error("evicted block should be forgotten.")
+	}
+}
+
+func TestAccountPoolCaps(t *testing.T) {
+	tester := newForkTester(t)
+	p := tester.pool
+	p.caps.MaxAccountPools = 2
+
+	a := p.selfPendingAc("a")
+	orphan := common.NewAccountBlock(5, "a-5", "a-4", "a", time.Now(), 0, 0, 0, "", common.SEND, "a", "b", "", -1)
+	p.AddAccountBlock("a", orphan)
+	b := p.selfPendingAc("b")
+
+	// b is empty, it's evicted before a
+	p.selfPendingAc("c")
+	if _, ok := p.pendingAc.Load("b"); ok || p.acCount != 2 {
+		t.Fatalf("empty pool should be evicted. pools:%d", p.acCount)
+	}
+	if b.AddBlock(orphan) {
+		t.Error("block should not be added to evicted pool.")
+	}
+	if p.selfPendingAc("a") != a || len(a.blockpool.freeBlocks) != 1 {
+		t.Fatal("pool with orphans should be kept.")
+	}
+
+	// a keeps being busy, c is evicted
+	a.compactLock.TryLock()
+	p.selfPendingAc("d")
+	p.selfPendingAc("e")
+	a.compactLock.UnLock()
+	if _, ok := p.pendingAc.Load("a"); !ok || p.acCount != 2 {
+		t.Fatalf("busy pool should be kept. pools:%d", p.acCount)
+	}
+
+	// only pools with orphans are left
+	p.AddAccountBlock("e", common.NewAccountBlock(5, "e-5", "e-4", "e", time.Now(), 0, 0, 0, "", common.SEND, "e", "b", "", -1))
+	p.selfPendingAc("f")
+	_, okA := p.pendingAc.Load("a")
+	_, okE := p.pendingAc.Load("e")
+	if p.acCount != 2 || okA == okE {
+		t.Fatalf("pool with orphans should be evicted at last. pools:%d", p.acCount)
+	}
+}
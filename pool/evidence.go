@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/face"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+// slots older than the newest seen block by this window are forgotten
+const evidenceWindow = time.Hour
+
+// find snapshot blocks signed by the same producer for the same slot.
+// only blocks with valid hash and signature are remembered, others can't be evidence.
+type evidenceDetector struct {
+	reader face.SnapshotReader
+	// key: evidence id val: first block seen of the slot
+	slots  map[string]*common.SnapshotBlock
+	newest time.Time
+	mu     sync.Mutex
+}
+
+func newEvidenceDetector(reader face.SnapshotReader) *evidenceDetector {
+	return &evidenceDetector{reader: reader, slots: make(map[string]*common.SnapshotBlock)}
+}
+
+// return evidence if another block of the slot has been seen in pool or chain
+func (self *evidenceDetector) check(block *common.SnapshotBlock) *common.Evidence {
+	if tools.CalculateSnapshotHash(block) != block.Hash() || tools.VerifySignature(block) != nil {
+		return nil
+	}
+	id := common.EvidenceId(block.Signer(), block.Timestamp())
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	first, ok := self.slots[id]
+	if !ok {
+		// blocks in chain may be inserted before restart
+		first = self.reader.GetSnapshotByHeight(block.Height())
+		if first == nil || first.Signer() != block.Signer() || !first.Timestamp().Equal(block.Timestamp()) {
+			first = nil
+		}
+		self.remember(id, block)
+	}
+	if first == nil || first.Hash() == block.Hash() {
+		return nil
+	}
+	return common.NewEvidence(first, block)
+}
+
+func (self *evidenceDetector) remember(id string, block *common.SnapshotBlock) {
+	self.slots[id] = block
+	if !block.Timestamp().After(self.newest) {
+		return
+	}
+	self.newest = block.Timestamp()
+	deadline := self.newest.Add(-evidenceWindow)
+	for k, b := range self.slots {
+		if b.Timestamp().Before(deadline) {
+			delete(self.slots, k)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package pool
+
+import (
+	"crypto/ed25519"
+	"sync"
+	"testing"
+	"time"
+
+	ch "github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/syncer"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+type evidenceSender struct {
+	syncer.Sender
+	evidences []*common.Evidence
+}
+
+func (self *evidenceSender) BroadcastEvidences(evidences []*common.Evidence) error {
+	self.evidences = append(self.evidences, evidences...)
+	return nil
+}
+
+func signedSnapshotBlock(prev *common.SnapshotBlock, key ed25519.PrivateKey, t time.Time, accounts []*common.AccountHashH) *common.SnapshotBlock {
+	signer := common.PubKeyToAddress(key.Public().(ed25519.PublicKey))
+	block := common.NewSnapshotBlock(prev.Height()+1, "", prev.Hash(), signer.String(), t, accounts)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	tools.SignBlock(block, func(a common.Address, data []byte) ([]byte, []byte, error) {
+		return ed25519.Sign(key, data), key.Public().(ed25519.PublicKey), nil
+	})
+	return block
+}
+
+func TestEvidence(t *testing.T) {
+	tester := newForkTester(t)
+	bc := tester.pool.bc
+	sender := &evidenceSender{}
+	p := tester.pool
+	p.sender = sender
+
+	head, _ := bc.HeadSnapshot()
+	slot := tester.genesisTime.Add(time.Second)
+	a := signedSnapshotBlock(head, tester.keys[1], slot, nil)
+	b := signedSnapshotBlock(head, tester.keys[1], slot, []*common.AccountHashH{common.NewAccountHashH("x", "y", 1)})
+
+	p.checkEvidence(a)
+	p.checkEvidence(a)
+	if len(sender.evidences) != 0 {
+		t.Fatal("the same block is not evidence.")
+	}
+	// unsigned block can't be evidence
+	forged := common.NewSnapshotBlock(a.Height(), "", a.PreHash(), a.Signer(), slot,
+		[]*common.AccountHashH{common.NewAccountHashH("z", "z", 1)})
+	forged.SetHash(tools.CalculateSnapshotHash(forged))
+	p.checkEvidence(forged)
+	if len(sender.evidences) != 0 {
+		t.Fatal("unsigned block should be ignored.")
+	}
+
+	p.checkEvidence(b)
+	p.checkEvidence(b)
+	if len(sender.evidences) != 1 {
+		t.Fatalf("evidence should be broadcast once. %d", len(sender.evidences))
+	}
+	e := bc.GetEvidence(common.EvidenceId(a.Signer(), slot))
+	if e == nil || e.First.Hash() == e.Second.Hash() || tools.VerifyEvidence(e) != nil {
+		t.Fatal("evidence is not stored.", e)
+	}
+	if o := common.NewEvidence(b, a); o.First.Hash() != e.First.Hash() {
+		t.Error("evidence should not depend on order of blocks.")
+	}
+
+	// conflicting with block in chain
+	if err := bc.InsertSnapshotBlock(a); err != nil {
+		t.Fatal(err)
+	}
+	next := tester.genesisTime.Add(2 * time.Second)
+	c := signedSnapshotBlock(a, tester.keys[2], next, nil)
+	if err := bc.InsertSnapshotBlock(c); err != nil {
+		t.Fatal(err)
+	}
+	p.checkEvidence(signedSnapshotBlock(a, tester.keys[2], next, []*common.AccountHashH{common.NewAccountHashH("x", "y", 1)}))
+	if len(bc.ListEvidence()) != 2 || len(sender.evidences) != 2 {
+		t.Fatalf("evidence with chain block error. %d", len(bc.ListEvidence()))
+	}
+
+	// evidence from network is verified
+	tampered := &common.Evidence{First: e.First, Second: forged}
+	if err := p.AddEvidence(tampered); err == nil {
+		t.Error("tampered evidence should be rejected.")
+	}
+
+	// signer does not own the slot
+	third := tester.genesisTime.Add(3 * time.Second)
+	misplaced := common.NewEvidence(signedSnapshotBlock(c, tester.keys[4], third, nil),
+		signedSnapshotBlock(c, tester.keys[4], third, []*common.AccountHashH{common.NewAccountHashH("x", "y", 1)}))
+	if err := p.AddEvidence(misplaced); err == nil {
+		t.Error("evidence of a slot owned by another producer should be rejected.")
+	}
+
+	// slot is expired
+	late := signedSnapshotBlock(c, tester.keys[0], tester.genesisTime.Add(evidenceWindow+5*time.Second), nil)
+	if err := bc.InsertSnapshotBlock(late); err != nil {
+		t.Fatal(err)
+	}
+	expired := common.NewEvidence(signedSnapshotBlock(c, tester.keys[3], third, nil),
+		signedSnapshotBlock(c, tester.keys[3], third, []*common.AccountHashH{common.NewAccountHashH("x", "y", 1)}))
+	if err := p.AddEvidence(expired); err == nil {
+		t.Error("evidence of an expired slot should be rejected.")
+	}
+	if len(bc.ListEvidence()) != 2 || len(sender.evidences) != 2 {
+		t.Errorf("rejected evidence should not be stored. %d", len(bc.ListEvidence()))
+	}
+}
+
+// slot owner is not checked without consensus
+func TestEvidenceWithoutConsensus(t *testing.T) {
+	genesis := config.DefaultGenesis()
+	bc := ch.NewChain("", genesis)
+	p := NewPool(bc, &sync.RWMutex{}).(*pool)
+	p.Init(&nopFetcher{}, nil, nil)
+
+	head, _ := bc.HeadSnapshot()
+	slot := time.Unix(genesis.Timestamp, 0).Add(time.Second)
+	a := signedSnapshotBlock(head, config.DevKey(0), slot, nil)
+	b := signedSnapshotBlock(head, config.DevKey(0), slot, []*common.AccountHashH{common.NewAccountHashH("x", "y", 1)})
+	p.AddSnapshotBlock(a)
+	p.AddSnapshotBlock(b)
+	if e := bc.GetEvidence(common.EvidenceId(a.Signer(), slot)); e == nil {
+		t.Fatal("evidence should be stored.")
+	}
+}
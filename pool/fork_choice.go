@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"sync"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/consensus"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+// picks the chain which current chain of snapshot pool switches to
+type ForkChoice interface {
+	// current is returned if no chain is better than it
+	Choose(current Chain, chains []Chain) Chain
+}
+
+// weighs a fork by slots whose producers signed blocks of the fork in them,
+// a producer can't outweigh others by producing more blocks in its slots.
+// forks of equal weight are ordered by hash of their first blocks.
+type producerForkChoice struct {
+	cv consensus.ConsensusVerifier
+	// blocks weighed by the last choice, so a block in pool is verified once.
+	// keyed by block rather than hash, hash of a block is not checked before it's verified.
+	valids map[common.Block]bool
+	mu     sync.Mutex
+}
+
+// slots are checked by cv, any signer is counted if cv is nil
+func NewProducerForkChoice(cv consensus.ConsensusVerifier) ForkChoice {
+	return &producerForkChoice{cv: cv}
+}
+
+func (self *producerForkChoice) Choose(current Chain, chains []Chain) Chain {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	weighed := make(map[common.Block]bool)
+	best := current
+	for _, c := range chains {
+		if c.ChainId() == best.ChainId() {
+			continue
+		}
+		if self.better(c, best, weighed) {
+			best = c
+		}
+	}
+	self.valids = weighed
+	return best
+}
+
+// compare blocks of two chains above their fork point
+func (self *producerForkChoice) better(a Chain, b Chain, weighed map[common.Block]bool) bool {
+	fork, ok := forkHeight(a, b)
+	if !ok {
+		return false
+	}
+	wa, wb := self.weight(a, fork, weighed), self.weight(b, fork, weighed)
+	if wa != wb {
+		return wa > wb
+	}
+	if wa == 0 {
+		return false
+	}
+	return a.GetBlock(fork+1).Hash() < b.GetBlock(fork+1).Hash()
+}
+
+// distinct (signer, slot) of valid blocks
+func (self *producerForkChoice) weight(c Chain, fork int, weighed map[common.Block]bool) int {
+	slots := make(map[string]bool)
+	for h := fork + 1; h <= c.HeadHeight(); h++ {
+		block := c.GetBlock(h)
+		if block == nil {
+			break
+		}
+		valid, ok := weighed[block]
+		if !ok {
+			valid, ok = self.valids[block]
+		}
+		if !ok {
+			valid = self.valid(block)
+		}
+		weighed[block] = valid
+		if valid {
+			slots[common.EvidenceId(block.Signer(), block.Timestamp())] = true
+		}
+	}
+	return len(slots)
+}
+
+// blocks in pool are not verified yet, a signer is only counted with its own signature
+func (self *producerForkChoice) valid(b common.Block) bool {
+	block, ok := b.(*common.SnapshotBlock)
+	if !ok {
+		return false
+	}
+	if tools.CalculateSnapshotHash(block) != block.Hash() || tools.VerifySignature(block) != nil {
+		return false
+	}
+	if self.cv == nil {
+		return true
+	}
+	r, _ := self.cv.Verify(nil, block)
+	return r
+}
+
+// the highest height where both chains have the same block
+func forkHeight(a Chain, b Chain) (int, bool) {
+	i := a.HeadHeight()
+	if b.HeadHeight() < i {
+		i = b.HeadHeight()
+	}
+	for ; i >= 0; i-- {
+		ba, bb := a.GetBlock(i), b.GetBlock(i)
+		if ba == nil || bb == nil {
+			return 0, false
+		}
+		if ba.Hash() == bb.Hash() {
+			return i, true
+		}
+	}
+	return 0, false
+}
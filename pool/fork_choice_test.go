@@ -0,0 +1,180 @@
+package pool
+
+import (
+	"crypto/ed25519"
+	"sync"
+	"testing"
+	"time"
+
+	ch "github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/common/face"
+	"github.com/viteshan/naive-vite/consensus"
+)
+
+type nopFetcher struct {
+}
+
+func (self *nopFetcher) FetchAccount(address string, hash common.HashHeight, prevCnt int) {
+}
+
+func (self *nopFetcher) FetchSnapshot(hash common.HashHeight, prevCnt int) {
+}
+
+func (self *nopFetcher) Fetch(request face.FetchRequest) {
+}
+
+type forkTester struct {
+	t           *testing.T
+	pool        *pool
+	genesis     *common.SnapshotBlock
+	genesisTime time.Time
+	// slot i of round 0 belongs to keys[i]
+	keys []ed25519.PrivateKey
+}
+
+func newForkTester(t *testing.T) *forkTester {
+	genesis := config.DefaultGenesis()
+	genesisTime := time.Unix(genesis.Timestamp, 0)
+	bc := ch.NewChain("", genesis)
+	cs := consensus.NewConsensus(genesisTime, genesis.Producers, nil,
+		config.Consensus{Interval: 1, MemCnt: 5}, clock.NewManualClock(genesisTime))
+	p := NewPool(bc, &sync.RWMutex{}).(*pool)
+	p.Init(&nopFetcher{}, nil, cs)
+
+	self := &forkTester{t: t, pool: p, genesisTime: genesisTime}
+	self.genesis, _ = bc.GenesisSnapshot()
+	members, _ := cs.Members(genesisTime)
+	for _, m := range members {
+		for i := 0; i < len(members); i++ {
+			if config.DevAddress(i) == m {
+				self.keys = append(self.keys, config.DevKey(i))
+			}
+		}
+	}
+	return self
+}
+
+// blocks signed by keys of slots, one block after another
+func (self *forkTester) fork(slots ...int) []*common.SnapshotBlock {
+	var result []*common.SnapshotBlock
+	prev := self.genesis
+	for _, s := range slots {
+		block := signedSnapshotBlock(prev, self.keys[s%len(self.keys)], self.genesisTime.Add(time.Duration(s)*time.Second), nil)
+		result = append(result, block)
+		prev = block
+	}
+	return result
+}
+
+// a block signed by key of slot, but at another time
+func (self *forkTester) misplaced(prev *common.SnapshotBlock, slot int, at int) *common.SnapshotBlock {
+	return signedSnapshotBlock(prev, self.keys[slot], self.genesisTime.Add(time.Duration(at)*time.Second), nil)
+}
+
+func (self *forkTester) replay(forks ...[]*common.SnapshotBlock) {
+	sp := self.pool.pendingSc
+	for _, blocks := range forks {
+		for _, b := range blocks {
+			sp.AddBlock(b)
+		}
+		for i := 0; i < 3; i++ {
+			sp.loopGenSnippetChains()
+			sp.loopAppendChains()
+		}
+	}
+}
+
+func (self *forkTester) currentHead() string {
+	return self.pool.pendingSc.CurrentChain().Head().Hash()
+}
+
+func TestForkChoiceByProducers(t *testing.T) {
+	tester := newForkTester(t)
+	// a single producer extends its fork in its own slot of every round
+	private := tester.fork(5, 10, 15)
+	// three producers sign in wrong slots
+	a := tester.misplaced(tester.genesis, 1, 2)
+	b := tester.misplaced(a, 2, 3)
+	c := tester.misplaced(b, 3, 4)
+	misplaced := []*common.SnapshotBlock{a, b, c}
+	// a producer signs many blocks in its only slot
+	d := tester.misplaced(tester.genesis, 1, 6)
+	e := tester.misplaced(d, 1, 6)
+	f := tester.misplaced(e, 1, 6)
+	g := tester.misplaced(f, 1, 6)
+	repeated := []*common.SnapshotBlock{d, e, f, g}
+	honest := tester.fork(1, 2, 3, 4)
+
+	tester.replay(private)
+	if tester.currentHead() != private[2].Hash() {
+		t.Fatalf("private fork should be current. %s", tester.currentHead())
+	}
+	tester.replay(misplaced, repeated)
+	tester.pool.pendingSc.checkFork()
+	if tester.currentHead() != private[2].Hash() {
+		t.Fatal("blocks out of their slots or in the same slot should not weigh.")
+	}
+
+	tester.replay(honest)
+	if len(tester.pool.pendingSc.Chains()) != 4 {
+		t.Fatalf("four forks expected. %d", len(tester.pool.pendingSc.Chains()))
+	}
+	tester.pool.pendingSc.checkFork()
+	if tester.currentHead() != honest[3].Hash() {
+		t.Errorf("fork of more produced slots should be chosen. %s", tester.currentHead())
+	}
+}
+
+type countingVerifier struct {
+	cv    consensus.ConsensusVerifier
+	calls int
+}
+
+func (self *countingVerifier) Verify(reader consensus.SnapshotReader, block *common.SnapshotBlock) (bool, error) {
+	self.calls++
+	return self.cv.Verify(reader, block)
+}
+
+func TestForkChoiceCache(t *testing.T) {
+	tester := newForkTester(t)
+	cv := &countingVerifier{cv: tester.pool.cv}
+	tester.pool.SetForkChoice(NewProducerForkChoice(cv))
+	tester.replay(tester.fork(5, 10), tester.fork(1, 2, 3))
+	sp := tester.pool.pendingSc
+	sp.checkFork()
+	calls := cv.calls
+	if calls == 0 || calls > 5 {
+		t.Fatalf("each block should be verified once. %d", calls)
+	}
+	sp.checkFork()
+	if cv.calls != calls {
+		t.Errorf("validity of blocks should be cached. %d", cv.calls-calls)
+	}
+}
+
+func TestForkChoiceTieBreak(t *testing.T) {
+	var heads []string
+	for _, reverse := range []bool{false, true} {
+		tester := newForkTester(t)
+		forks := [][]*common.SnapshotBlock{tester.fork(1, 3), tester.fork(2, 4)}
+		if reverse {
+			forks[0], forks[1] = forks[1], forks[0]
+		}
+		tester.replay(forks...)
+		tester.pool.pendingSc.checkFork()
+		expected := forks[0]
+		if forks[1][0].Hash() < forks[0][0].Hash() {
+			expected = forks[1]
+		}
+		if tester.currentHead() != expected[len(expected)-1].Hash() {
+			t.Errorf("fork with lower hash should be chosen. %s", tester.currentHead())
+		}
+		heads = append(heads, tester.currentHead())
+	}
+	if heads[0] != heads[1] {
+		t.Error("chosen fork should not depend on arrival order.")
+	}
+}
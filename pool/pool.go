@@ -4,16 +4,20 @@ import (
 	"encoding/json"
 
 	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/common/log"
+	"github.com/viteshan/naive-vite/consensus"
 	"github.com/viteshan/naive-vite/syncer"
+	"github.com/viteshan/naive-vite/tools"
 	"github.com/viteshan/naive-vite/verifier"
 
+	"runtime"
 	"sync"
 
 	"time"
 
-	"fmt"
+	"errors"
 
 	ch "github.com/viteshan/naive-vite/chain"
 	"github.com/viteshan/naive-vite/monitor"
@@ -25,22 +29,37 @@ type BlockPool interface {
 	face.PoolReader
 	Start()
 	Stop()
-	Init(syncer.Fetcher)
-	Info(string) string
+	// blocks of snapshot chain are checked by cv, evidences of double signing are broadcast by s
+	Init(f syncer.Fetcher, s syncer.Sender, cv consensus.ConsensusVerifier)
+	// forks and orphan blocks of the account pool, snapshot pool if address is empty
+	Info(address string) (*Report, error)
+	// replace the default fork choice of snapshot pool, which weighs forks by producers
+	SetForkChoice(fc ForkChoice)
+	// caps of orphan blocks and reorg depth, must be set before Init
+	SetCaps(caps config.Pool)
 }
 
 type pool struct {
 	pendingSc *snapshotPool
 	pendingAc sync.Map
 	fetcher   syncer.Fetcher
+	sender    syncer.Sender
 	bc        ch.BlockChain
+	evidences *evidenceDetector
+	cv        consensus.ConsensusVerifier
 
 	snapshotVerifier *verifier.SnapshotVerifier
 	accountVerifier  *verifier.AccountVerifier
 
 	rwMutex *sync.RWMutex
 	acMu    sync.Mutex
+	// account pools in pendingAc, guarded by acMu
+	acCount int
 	version *version.Version
+	caps    config.Pool
+	signals *signals
+	// account chains inserted concurrently
+	workers int
 
 	closed chan struct{}
 	wg     sync.WaitGroup
@@ -48,55 +67,59 @@ type pool struct {
 
 func NewPool(bc ch.BlockChain, rwMutex *sync.RWMutex) BlockPool {
 	self := &pool{bc: bc, rwMutex: rwMutex, version: &version.Version{}, closed: make(chan struct{})}
+	self.caps = config.DefaultPool()
+	self.signals = newSignals()
+	self.workers = runtime.NumCPU()
 	return self
 }
 
-func (self *pool) Init(f syncer.Fetcher) {
-	self.snapshotVerifier = verifier.NewSnapshotVerifier(self.bc, self.version)
+func (self *pool) Init(f syncer.Fetcher, s syncer.Sender, cv consensus.ConsensusVerifier) {
+	self.snapshotVerifier = verifier.NewSnapshotVerifier(self.bc, cv, self.version)
 	self.accountVerifier = verifier.NewAccountVerifier(self.bc, self.version)
 	self.fetcher = f
+	self.sender = s
+	self.cv = cv
+	self.evidences = newEvidenceDetector(self.bc)
 	snapshotPool := newSnapshotPool("snapshotPool", self.version)
+	snapshotPool.caps = poolCaps{maxBlocks: self.caps.MaxBlocks, maxSnippetLen: self.caps.MaxSnippetLen, maxDistance: self.caps.MaxDistance,
+		maxReorgDepth: self.caps.MaxReorgDepth}
 	snapshotPool.init(&snapshotCh{self.bc, self.version},
 		self.snapshotVerifier,
 		NewFetcher("", self.fetcher),
 		self.rwMutex,
-		self)
+		self,
+		NewProducerForkChoice(cv))
 
 	self.pendingSc = snapshotPool
+	// pools waiting for other chains are signaled by insertions
+	self.bc.AddChainListener(self)
+}
+func (self *pool) SetForkChoice(fc ForkChoice) {
+	self.pendingSc.forkChoice = fc
+}
+
+func (self *pool) SetCaps(caps config.Pool) {
+	self.caps = caps.WithDefault()
 }
-func (self *pool) Info(id string) string {
-	if id == "" {
-		bp := self.pendingSc.blockpool
-		cp := self.pendingSc.chainpool
-
-		freeSize := len(bp.freeBlocks)
-		compoundSize := len(bp.compoundBlocks)
-		snippetSize := len(cp.snippetChains)
-		currentLen := cp.current.size()
-		chainSize := len(cp.chains)
-		return fmt.Sprintf("freeSize:%d, compoundSize:%d, snippetSize:%d, currentLen:%d, chainSize:%d",
-			freeSize, compoundSize, snippetSize, currentLen, chainSize)
-	} else {
-		ac := self.selfPendingAc(id)
-		if ac == nil {
-			return "pool not exist."
-		}
-		bp := ac.blockpool
-		cp := ac.chainpool
 
-		freeSize := len(bp.freeBlocks)
-		compoundSize := len(bp.compoundBlocks)
-		snippetSize := len(cp.snippetChains)
-		currentLen := cp.current.size()
-		chainSize := len(cp.chains)
-		return fmt.Sprintf("freeSize:%d, compoundSize:%d, snippetSize:%d, currentLen:%d, chainSize:%d",
-			freeSize, compoundSize, snippetSize, currentLen, chainSize)
+func (self *pool) Info(address string) (*Report, error) {
+	if address == "" {
+		return self.pendingSc.report(), nil
+	}
+	p, ok := self.pendingAc.Load(address)
+	if !ok {
+		return nil, errors.New("pool not exist. address:" + address)
 	}
+	return p.(*accountPool).report(retryInterval)
 }
 func (self *pool) Start() {
 	self.pendingSc.Start()
-	go self.loopTryInsert()
-	go self.loopCompact()
+	for i := 0; i < self.workers; i++ {
+		self.wg.Add(1)
+		go self.loopInsert()
+	}
+	self.wg.Add(1)
+	go self.loopRetry()
 }
 func (self *pool) Stop() {
 	self.pendingSc.Stop()
@@ -106,19 +129,70 @@ func (self *pool) Stop() {
 
 func (self *pool) AddSnapshotBlock(block *common.SnapshotBlock) error {
 	log.Info("receive snapshot block from network. height:%d, hash:%s.", block.Height(), block.Hash())
+	self.checkEvidence(block)
 	self.pendingSc.AddBlock(block)
+	self.signals.snapshot()
 	return nil
 }
 
 func (self *pool) AddDirectSnapshotBlock(block *common.SnapshotBlock) error {
+	self.checkEvidence(block)
 	self.rwMutex.RLock()
 	defer self.rwMutex.RUnlock()
 	return self.pendingSc.AddDirectBlock(block)
 }
 
+// conflicting blocks are still forks of pool, evidence only records the producer.
+func (self *pool) checkEvidence(block *common.SnapshotBlock) {
+	e := self.evidences.check(block)
+	if e == nil {
+		return
+	}
+	err := self.AddEvidence(e)
+	if err != nil {
+		log.Error("add evidence fail. id:%s, err:%v", e.Id(), err)
+	}
+}
+
+// evidence is stored and broadcast once, known evidence is ignored.
+// the signer must own the slot if cv is set, and slots older than evidenceWindow before snapshot head are refused.
+func (self *pool) AddEvidence(e *common.Evidence) error {
+	err := tools.VerifyEvidence(e)
+	if err != nil {
+		return err
+	}
+	head, err := self.bc.HeadSnapshot()
+	if err != nil {
+		return err
+	}
+	if e.Timestamp().Before(head.Timestamp().Add(-evidenceWindow)) {
+		return errors.New("slot[" + e.Timestamp().Format(time.RFC3339) + "] of evidence is expired")
+	}
+	if self.cv != nil {
+		ok, err := self.cv.Verify(nil, e.First)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("signer[" + e.Signer() + "] of evidence does not own the slot")
+		}
+	}
+	if !self.bc.PutEvidence(e) {
+		return nil
+	}
+	log.Warn("producer[%s] signed two snapshot blocks for slot[%s]. hash:%s, %s.",
+		e.Signer(), e.Timestamp(), e.First.Hash(), e.Second.Hash())
+	if self.sender != nil {
+		return self.sender.BroadcastEvidences([]*common.Evidence{e})
+	}
+	return nil
+}
+
 func (self *pool) AddAccountBlock(address string, block *common.AccountStateBlock) error {
 	log.Info("receive account block from network. addr:%s, height:%d, hash:%s.", address, block.Height(), block.Hash())
-	self.selfPendingAc(address).AddBlock(block)
+	for !self.selfPendingAc(address).AddBlock(block) {
+	}
+	self.signals.account(address)
 	return nil
 }
 
@@ -134,7 +208,8 @@ func (self *pool) ExistInPool(address string, requestHash string) bool {
 	panic("implement me")
 }
 
-func (self *pool) ForkAccounts(keyPoint *common.SnapshotBlock, forkPoint *common.SnapshotBlock) error {
+// return account chains rolled back, new heads of them
+func (self *pool) ForkAccounts(keyPoint *common.SnapshotBlock, forkPoint *common.SnapshotBlock) ([]*common.AccountHashH, error) {
 	tasks := make(map[string]*common.AccountHashH)
 	self.pendingAc.Range(func(k, v interface{}) bool {
 		a := v.(*accountPool)
@@ -154,16 +229,19 @@ func (self *pool) ForkAccounts(keyPoint *common.SnapshotBlock, forkPoint *common
 	})
 	waitRollbackAccounts := self.getWaitRollbackAccounts(tasks)
 
+	var result []*common.AccountHashH
 	for _, v := range waitRollbackAccounts {
 		err := self.selfPendingAc(v.Addr).Rollback(v.Height, v.Hash)
 		if err != nil {
-			return err
+			return result, err
 		}
+		result = append(result, v)
 	}
 	for _, v := range keyPoint.Accounts {
-		self.ForkAccountTo(v)
+		rollbacks, _ := self.ForkAccountTo(v)
+		result = append(result, rollbacks...)
 	}
-	return nil
+	return result, nil
 }
 func (self *pool) getWaitRollbackAccounts(tasks map[string]*common.AccountHashH) map[string]*common.AccountHashH {
 	waitRollback := make(map[string]*common.AccountHashH)
@@ -239,7 +317,8 @@ func (self *pool) PendingAccountTo(h *common.AccountHashH) error {
 	return nil
 }
 
-func (self *pool) ForkAccountTo(h *common.AccountHashH) error {
+// return account chains rolled back, new heads of them
+func (self *pool) ForkAccountTo(h *common.AccountHashH) ([]*common.AccountHashH, error) {
 	this := self.selfPendingAc(h.Addr)
 
 	inChain := this.FindInChain(h.Hash, h.Height)
@@ -247,37 +326,30 @@ func (self *pool) ForkAccountTo(h *common.AccountHashH) error {
 	log.Info("inChain:%v, accounts:%s", inChain, string(bytes))
 	if !inChain {
 		self.fetcher.Fetch(face.FetchRequest{Chain: h.Addr, Height: h.Height, Hash: h.Hash, PrevCnt: 5})
-		return nil
+		return nil, nil
 	}
 	ok, block, chain, err := this.FindRollbackPointForAccountHashH(h.Height, h.Hash)
 	if err != nil {
 		log.Error("%v", err)
 	}
 	if !ok {
-		return nil
+		return nil, nil
 	}
 
 	tasks := make(map[string]*common.AccountHashH)
 	tasks[h.Addr] = common.NewAccountHashH(h.Addr, block.Hash(), block.Height())
 	waitRollback := self.getWaitRollbackAccounts(tasks)
+	var result []*common.AccountHashH
 	for _, v := range waitRollback {
-		self.selfPendingAc(v.Addr).Rollback(v.Height, v.Hash)
+		if self.selfPendingAc(v.Addr).Rollback(v.Height, v.Hash) == nil {
+			result = append(result, v)
+		}
 	}
 	err = this.CurrentModifyToChain(chain)
 	if err != nil {
 		log.Error("%v", err)
 	}
-	return err
-}
-
-func (self *pool) UnLockAccounts(startAcs map[string]*common.SnapshotPoint, endAcs map[string]*common.SnapshotPoint) error {
-	for k, v := range startAcs {
-		err := self.bc.RollbackSnapshotPoint(k, v, endAcs[k])
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return result, err
 }
 
 func (self *pool) selfPendingAc(addr string) *accountPool {
@@ -288,6 +360,8 @@ func (self *pool) selfPendingAc(addr string) *accountPool {
 	}
 
 	p := newAccountPool("accountChainPool-"+addr, &accountCh{addr, self.bc, self.version}, self.version)
+	p.caps = poolCaps{maxBlocks: self.caps.MaxAccountBlocks, maxSnippetLen: self.caps.MaxSnippetLen, maxDistance: self.caps.MaxDistance,
+		maxReorgDepth: self.caps.MaxReorgDepth}
 	p.Init(self.accountVerifier, NewFetcher(addr, self.fetcher), self.rwMutex.RLocker())
 
 	self.acMu.Lock()
@@ -296,90 +370,112 @@ func (self *pool) selfPendingAc(addr string) *accountPool {
 	if ok {
 		return chain.(*accountPool)
 	}
+	if self.acCount >= self.caps.MaxAccountPools {
+		self.evictAc()
+	}
 	self.pendingAc.Store(addr, p)
+	self.acCount++
 	return p
 
 }
-func (self *pool) loopTryInsert() {
-	self.wg.Add(1)
-	defer self.wg.Done()
 
-	t := time.NewTicker(time.Millisecond * 20)
-	sum := 0
-	for {
-		select {
-		case <-self.closed:
-			return
-		case <-t.C:
-			if sum == 0 {
-				time.Sleep(time.Millisecond * 10)
-				monitor.LogEvent("pool", "tryInsertSleep")
+// evict an idle account pool, one without blocks is preferred to one with only orphan blocks.
+// must be called with acMu locked.
+func (self *pool) evictAc() {
+	for _, orphans := range []bool{false, true} {
+		evicted := false
+		self.pendingAc.Range(func(k, v interface{}) bool {
+			if v.(*accountPool).evict(orphans) {
+				self.pendingAc.Delete(k)
+				self.acCount--
+				evicted = true
+				return false
 			}
-			sum = 0
-			sum += self.accountsTryInsert()
-		default:
-			sum += self.accountsTryInsert()
+			return true
+		})
+		if evicted {
+			return
 		}
 	}
+	log.Warn("no account pool is idle, pools:%d.", self.acCount)
 }
 
-func (self *pool) accountsTryInsert() int {
-	monitor.LogEvent("pool", "tryInsert")
-	sum := 0
-	var pending []*accountPool
-	self.pendingAc.Range(func(_, v interface{}) bool {
-		p := v.(*accountPool)
-		pending = append(pending, p)
-		return true
-	})
-	var tasks []verifier.Task
-	for _, p := range pending {
-		task := p.TryInsert()
-		if task != nil {
-			self.fetchForTask(task)
-			tasks = append(tasks, task)
-			sum = sum + 1
+// account chains are independent except receive blocks and snapshot references,
+// which are pending in verifier until the referred chain is inserted.
+// so workers insert blocks of different accounts concurrently.
+func (self *pool) loopInsert() {
+	defer self.wg.Done()
+	for {
+		select {
+		case <-self.closed:
+			return
+		case <-self.signals.accountCh:
+			for {
+				address, ok := self.signals.next()
+				if !ok {
+					break
+				}
+				self.accountWork(address, self.selfPendingAc(address))
+				self.signals.done(address)
+			}
 		}
 	}
-	return sum
 }
 
-func (self *pool) loopCompact() {
-	self.wg.Add(1)
+func (self *pool) loopRetry() {
 	defer self.wg.Done()
 
-	t := time.NewTicker(time.Millisecond * 40)
-	sum := 0
+	t := time.NewTicker(retryInterval)
+	defer t.Stop()
 	for {
 		select {
 		case <-self.closed:
 			return
 		case <-t.C:
-			if sum == 0 {
-				time.Sleep(time.Millisecond * 20)
-			}
-			sum = 0
-
-			sum += self.accountsCompact()
-		default:
-			sum += self.accountsCompact()
+			self.accountsRetry()
 		}
 	}
 }
 
-func (self *pool) accountsCompact() int {
-	sum := 0
-	var pendings []*accountPool
-	self.pendingAc.Range(func(_, v interface{}) bool {
+// compact new blocks and insert them to chain
+func (self *pool) accountWork(address string, p *accountPool) {
+	p.Compact()
+	monitor.LogEvent("pool", "tryInsert")
+	task := p.TryInsert()
+	if task != nil {
+		self.fetchForTask(task)
+		self.signals.wait(address, task.Requests())
+	}
+}
+
+// account pools which still have blocks are retried, missing blocks are fetched again
+func (self *pool) accountsRetry() {
+	monitor.LogEvent("pool", "retry")
+	self.pendingAc.Range(func(k, v interface{}) bool {
 		p := v.(*accountPool)
-		pendings = append(pendings, p)
+		if p.RetryFetch() {
+			self.signals.account(k.(string))
+		}
 		return true
 	})
-	for _, p := range pendings {
-		sum = sum + p.Compact()
-	}
-	return sum
 }
+
+func (self *pool) SnapshotInsertCallback(block *common.SnapshotBlock) {
+	self.signals.inserted("")
+}
+
+func (self *pool) SnapshotRemoveCallback(block *common.SnapshotBlock) {
+}
+
+func (self *pool) AccountInsertCallback(address string, block *common.AccountStateBlock) {
+	self.signals.inserted(address)
+	// snapshot block may wait for the account
+	self.signals.snapshot()
+}
+
+func (self *pool) AccountRemoveCallback(address string, block *common.AccountStateBlock) {
+}
+
 func (self *pool) fetchForTask(task verifier.Task) []*face.FetchRequest {
 	reqs := task.Requests()
 	if len(reqs) <= 0 {
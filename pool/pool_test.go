@@ -0,0 +1,111 @@
+package pool
+
+import (
+	"crypto/ed25519"
+	"sync"
+	"testing"
+	"time"
+
+	ch "github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/tools"
+)
+
+// account blocks inserted to chain
+type insertListener struct {
+	inserted chan *common.AccountStateBlock
+}
+
+func (self *insertListener) SnapshotInsertCallback(block *common.SnapshotBlock) {
+}
+
+func (self *insertListener) SnapshotRemoveCallback(block *common.SnapshotBlock) {
+}
+
+func (self *insertListener) AccountInsertCallback(address string, block *common.AccountStateBlock) {
+	self.inserted <- block
+}
+
+func (self *insertListener) AccountRemoveCallback(address string, block *common.AccountStateBlock) {
+}
+
+func signedSendBlock(prev *common.AccountStateBlock, key ed25519.PrivateKey, snapshot *common.SnapshotBlock, to string) *common.AccountStateBlock {
+	from := common.PubKeyToAddress(key.Public().(ed25519.PublicKey)).String()
+	block := common.NewAccountBlockFrom(prev, from, time.Now(), 0, snapshot, common.SEND, from, to, "", -1)
+	block.SetHash(tools.CalculateAccountHash(block))
+	tools.SignBlock(block, func(a common.Address, data []byte) ([]byte, []byte, error) {
+		return ed25519.Sign(key, data), key.Public().(ed25519.PublicKey), nil
+	})
+	return block
+}
+
+func startedPool(genesis *config.Genesis, listener *insertListener) (ch.BlockChain, *pool) {
+	bc := ch.NewChain("", genesis)
+	bc.AddChainListener(listener)
+	p := NewPool(bc, &sync.RWMutex{}).(*pool)
+	p.Init(&nopFetcher{}, nil, nil)
+	p.Start()
+	return bc, p
+}
+
+// latency from receiving an account block to inserting it to chain
+func BenchmarkAddAccountBlock(b *testing.B) {
+	listener := &insertListener{inserted: make(chan *common.AccountStateBlock, 1)}
+	bc, p := startedPool(config.DefaultGenesis(), listener)
+	defer p.Stop()
+
+	from := config.DevAddress(0).String()
+	snapshot, _ := bc.HeadSnapshot()
+	prev, _ := bc.HeadAccount(from)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		block := signedSendBlock(prev, config.DevKey(0), snapshot, config.DevAddress(1).String())
+		p.AddAccountBlock(from, block)
+		select {
+		case inserted := <-listener.inserted:
+			if inserted.Hash() != block.Hash() {
+				b.Fatalf("unexpected block inserted. %s", inserted.Hash())
+			}
+		case <-time.After(5 * time.Second):
+			b.Fatalf("block is not inserted. height:%d", block.Height())
+		}
+		prev = block
+	}
+	b.StopTimer()
+}
+
+// throughput of inserting blocks of independent accounts
+func BenchmarkAddAccountBlocksParallel(b *testing.B) {
+	const accounts = 16
+	genesis := config.DefaultGenesis()
+	genesis.Accounts = nil
+	for i := 0; i < accounts; i++ {
+		genesis.Accounts = append(genesis.Accounts, config.GenesisAccount{Address: config.DevAddress(i).String(), Balance: 200})
+	}
+	listener := &insertListener{inserted: make(chan *common.AccountStateBlock, accounts)}
+	bc, p := startedPool(genesis, listener)
+	defer p.Stop()
+
+	snapshot, _ := bc.HeadSnapshot()
+	prevs := make([]*common.AccountStateBlock, accounts)
+	for i := range prevs {
+		prevs[i], _ = bc.HeadAccount(config.DevAddress(i).String())
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range prevs {
+			block := signedSendBlock(prevs[i], config.DevKey(i), snapshot, config.DevAddress((i+1)%accounts).String())
+			p.AddAccountBlock(config.DevAddress(i).String(), block)
+			prevs[i] = block
+		}
+		for i := 0; i < accounts; i++ {
+			select {
+			case <-listener.inserted:
+			case <-time.After(5 * time.Second):
+				b.Fatalf("blocks are not inserted. round:%d, inserted:%d", n, i)
+			}
+		}
+	}
+	b.StopTimer()
+}
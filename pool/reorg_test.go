@@ -0,0 +1,64 @@
+package pool
+
+import (
+	"testing"
+)
+
+// always switches to the chain of head
+type headChoice struct {
+	head string
+}
+
+func (self *headChoice) Choose(current Chain, chains []Chain) Chain {
+	for _, c := range chains {
+		if c.Head() != nil && c.Head().Hash() == self.head {
+			return c
+		}
+	}
+	return current
+}
+
+func TestReorgDepth(t *testing.T) {
+	for _, depth := range []int{2, 3} {
+		tester := newForkTester(t)
+		sp := tester.pool.pendingSc
+		sp.caps.maxReorgDepth = depth
+		honest := tester.fork(1, 2, 3)
+		tester.replay(honest)
+		sp.loopCheckCurrentInsert()
+		head := sp.chainpool.diskChain.Head()
+		if head.Hash() != honest[2].Hash() {
+			t.Fatalf("honest fork should be inserted. %d", head.Height())
+		}
+
+		other := tester.fork(6, 7, 8, 9)
+		tester.replay(other)
+		tester.pool.SetForkChoice(&headChoice{head: other[3].Hash()})
+		sp.checkFork()
+
+		reorgs := tester.pool.bc.ListReorg()
+		if depth == 2 {
+			// 3 blocks would be rolled back
+			if tester.currentHead() != honest[2].Hash() || len(reorgs) != 0 {
+				t.Errorf("deep reorg should be refused. current:%s, reorgs:%d", tester.currentHead(), len(reorgs))
+			}
+			if len(sp.acceptableChains()) != len(sp.Chains())-1 {
+				t.Error("refused chain should not be chosen again.")
+			}
+			continue
+		}
+		if tester.currentHead() != other[3].Hash() {
+			t.Fatalf("reorg should be done. current:%s", tester.currentHead())
+		}
+		if len(reorgs) != 1 {
+			t.Fatalf("reorg should be journaled. %d", len(reorgs))
+		}
+		r := reorgs[0]
+		if r.ForkPoint.Hash != tester.genesis.Hash() || len(r.Removed) != 3 || len(r.Added) != 4 {
+			t.Errorf("unexpected reorg. %v", r)
+		}
+		if r.Removed[0].Hash != honest[2].Hash() || r.Added[3].Hash != other[3].Hash() {
+			t.Errorf("removed blocks from old head, added blocks to new head. %v", r)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package pool
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+// a chain of pool, blocks above tail and up to head
+type ChainReport struct {
+	Id string
+	// chain it's forked from, disk chain or another forked chain. empty for snippet chains
+	Refer   string `json:",omitempty"`
+	Tail    common.HashHeight
+	Head    common.HashHeight
+	Current bool
+	// distinct signers of blocks above tail
+	Signers []string
+}
+
+// forks and orphan blocks of an account pool or the snapshot pool
+type Report struct {
+	Id       string
+	DiskId   string
+	Disk     common.HashHeight // head of chain on disk
+	Current  string
+	Chains   []*ChainReport
+	Snippets []*ChainReport
+	Free     []common.HashHeight
+	Compound int
+}
+
+// caller must keep the pool from being compacted and inserted
+func (self *BCPool) report() *Report {
+	cp := self.chainpool
+	result := &Report{Id: self.Id, DiskId: cp.diskChain.id(), Current: cp.current.id()}
+	disk := cp.diskChain.Head()
+	if disk != nil {
+		result.Disk = common.HashHeight{Hash: disk.Hash(), Height: disk.Height()}
+	}
+	for _, c := range cp.chains {
+		r := chainReport(&c.chain, c.tailHash, c.headHash)
+		r.Refer = c.referChain.id()
+		r.Current = c == cp.current
+		result.Chains = append(result.Chains, r)
+	}
+	for _, s := range cp.snippetChains {
+		result.Snippets = append(result.Snippets, chainReport(&s.chain, s.tailHash, s.headHash))
+	}
+	sort.Sort(byChainId(result.Chains))
+	sort.Sort(byChainId(result.Snippets))
+
+	pendingMu.Lock()
+	for _, w := range self.blockpool.freeBlocks {
+		result.Free = append(result.Free, common.HashHeight{Hash: w.block.Hash(), Height: w.block.Height()})
+	}
+	result.Compound = len(self.blockpool.compoundBlocks)
+	pendingMu.Unlock()
+	sort.Slice(result.Free, func(i, j int) bool {
+		if result.Free[i].Height != result.Free[j].Height {
+			return result.Free[i].Height < result.Free[j].Height
+		}
+		return result.Free[i].Hash < result.Free[j].Hash
+	})
+	return result
+}
+
+func chainReport(c *chain, tailHash string, headHash string) *ChainReport {
+	result := &ChainReport{
+		Id:   c.id(),
+		Tail: common.HashHeight{Hash: tailHash, Height: c.tailHeight},
+		Head: common.HashHeight{Hash: headHash, Height: c.headHeight},
+	}
+	signers := make(map[string]bool)
+	for h := c.tailHeight + 1; h <= c.headHeight; h++ {
+		w, ok := c.heightBlocks[h]
+		if ok && !signers[w.block.Signer()] {
+			signers[w.block.Signer()] = true
+			result.Signers = append(result.Signers, w.block.Signer())
+		}
+	}
+	sort.Strings(result.Signers)
+	return result
+}
+
+type byChainId []*ChainReport
+
+func (a byChainId) Len() int           { return len(a) }
+func (a byChainId) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byChainId) Less(i, j int) bool { return a[i].Id < a[j].Id }
+
+// fork tree in graphviz dot. forked chains hang on the chain they refer to at their tail,
+// current chain is bold, snippet chains and free blocks are dashed since they are not linked yet.
+func (self *Report) Dot() string {
+	var b strings.Builder
+	b.WriteString("digraph " + strconv.Quote(self.Id) + " {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+	b.WriteString("\t" + strconv.Quote(self.DiskId) + " [label=" + strconv.Quote("disk\n"+hashHeightLabel(self.Disk)) + ", shape=cylinder];\n")
+	for _, c := range self.Chains {
+		attrs := "label=" + strconv.Quote(chainLabel(c))
+		if c.Current {
+			attrs += ", style=bold, color=red"
+		}
+		b.WriteString("\t" + strconv.Quote(c.Id) + " [" + attrs + "];\n")
+		if c.Refer != "" {
+			b.WriteString("\t" + strconv.Quote(c.Refer) + " -> " + strconv.Quote(c.Id) +
+				" [label=" + strconv.Quote(strconv.Itoa(c.Tail.Height)) + "];\n")
+		}
+	}
+	for _, s := range self.Snippets {
+		b.WriteString("\t" + strconv.Quote(s.Id) + " [label=" + strconv.Quote(chainLabel(s)) + ", style=dashed];\n")
+	}
+	for _, f := range self.Free {
+		b.WriteString("\t" + strconv.Quote(f.Hash) + " [label=" + strconv.Quote(hashHeightLabel(f)) + ", shape=ellipse, style=dashed];\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func chainLabel(c *ChainReport) string {
+	return c.Id + "\n" +
+		"tail " + hashHeightLabel(c.Tail) + "\n" +
+		"head " + hashHeightLabel(c.Head) + "\n" +
+		"signers " + strconv.Itoa(len(c.Signers))
+}
+
+func hashHeightLabel(h common.HashHeight) string {
+	hash := h.Hash
+	if len(hash) > 8 {
+		hash = hash[:8]
+	}
+	return strconv.Itoa(h.Height) + " " + hash
+}
+
+// an account pool is compacted and inserted by one worker, wait for it
+func (self *accountPool) report(timeout time.Duration) (*Report, error) {
+	deadline := time.Now().Add(timeout)
+	for !self.compactLock.TryLock() {
+		if time.Now().After(deadline) {
+			return nil, errors.New("pool is busy. pool:" + self.Id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	defer self.compactLock.UnLock()
+	return self.BCPool.report(), nil
+}
+
+func (self *snapshotPool) report() *Report {
+	self.workMu.Lock()
+	defer self.workMu.Unlock()
+	return self.BCPool.report()
+}
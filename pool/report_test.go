@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	tester := newForkTester(t)
+	honest := tester.fork(1, 2, 3)
+	private := tester.fork(5, 10)
+	orphan := tester.fork(1, 2, 3, 4, 6)
+	tester.replay(honest, private)
+	tester.pool.pendingSc.checkFork()
+	tester.pool.pendingSc.AddBlock(orphan[4])
+
+	report, err := tester.pool.Info("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Chains) != 2 {
+		t.Fatalf("two forks expected. %d", len(report.Chains))
+	}
+	var current *ChainReport
+	for _, c := range report.Chains {
+		if c.Current {
+			current = c
+		}
+		if c.Refer == "" {
+			t.Errorf("forked chain should refer to a chain. %s", c.Id)
+		}
+	}
+	if current == nil || current.Id != report.Current || current.Head.Hash != honest[2].Hash() {
+		t.Fatalf("honest fork should be current. %v", current)
+	}
+	if len(current.Signers) != 3 {
+		t.Errorf("three signers of honest fork expected. %v", current.Signers)
+	}
+	if len(report.Free) != 1 || report.Free[0].Hash != orphan[4].Hash() {
+		t.Errorf("orphan block should be free. %v", report.Free)
+	}
+
+	dot := report.Dot()
+	for _, c := range report.Chains {
+		edge := strconv.Quote(c.Refer) + " -> " + strconv.Quote(c.Id)
+		if !strings.Contains(dot, edge) {
+			t.Errorf("edge missing. %s\n%s", edge, dot)
+		}
+	}
+	if !strings.Contains(dot, "style=bold") || !strings.Contains(dot, strconv.Quote(orphan[4].Hash())) {
+		t.Errorf("current chain and free block should be rendered.\n%s", dot)
+	}
+
+	if _, err := tester.pool.Info("unknown"); err == nil {
+		t.Error("unknown account pool should fail.")
+	}
+}
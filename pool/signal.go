@@ -0,0 +1,124 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/viteshan/naive-vite/common/face"
+)
+
+// pools which are not signaled are retried at this interval, eg: fetch again, verify task timeout.
+const retryInterval = time.Second
+
+// wake up exactly the pools affected by new blocks and chain insertions
+type signals struct {
+	mu sync.Mutex
+	// account pools to work on
+	accounts map[string]bool
+	// account pools being worked on, an account is worked by one worker at a time
+	running map[string]bool
+	// key: chain waited for, "" is snapshot chain. val: waiting accounts
+	waiting map[string]map[string]bool
+
+	accountCh  chan struct{}
+	snapshotCh chan struct{}
+}
+
+func newSignals() *signals {
+	return &signals{
+		accounts:   make(map[string]bool),
+		running:    make(map[string]bool),
+		waiting:    make(map[string]map[string]bool),
+		accountCh:  make(chan struct{}, 1),
+		snapshotCh: make(chan struct{}, 1),
+	}
+}
+
+func (self *signals) account(address string) {
+	self.mu.Lock()
+	self.accounts[address] = true
+	self.mu.Unlock()
+	wake(self.accountCh)
+}
+
+func (self *signals) snapshot() {
+	wake(self.snapshotCh)
+}
+
+// address is signaled again when chains of reqs are inserted
+func (self *signals) wait(address string, reqs []face.FetchRequest) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, r := range reqs {
+		w, ok := self.waiting[r.Chain]
+		if !ok {
+			w = make(map[string]bool)
+			self.waiting[r.Chain] = w
+		}
+		w[address] = true
+	}
+}
+
+// a block is inserted to chain, "" is snapshot chain
+func (self *signals) inserted(chain string) {
+	self.mu.Lock()
+	w, ok := self.waiting[chain]
+	if ok {
+		delete(self.waiting, chain)
+		for address := range w {
+			self.accounts[address] = true
+		}
+	}
+	self.mu.Unlock()
+	if ok {
+		wake(self.accountCh)
+	}
+}
+
+// take an account which is not being worked on, another worker is woken up if more are left
+func (self *signals) next() (string, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := ""
+	found := false
+	left := false
+	for address := range self.accounts {
+		if self.running[address] {
+			continue
+		}
+		if found {
+			left = true
+			break
+		}
+		result = address
+		found = true
+	}
+	if !found {
+		return "", false
+	}
+	delete(self.accounts, result)
+	self.running[result] = true
+	if left {
+		wake(self.accountCh)
+	}
+	return result, true
+}
+
+// account signaled while being worked on is worked again
+func (self *signals) done(address string) {
+	self.mu.Lock()
+	delete(self.running, address)
+	again := self.accounts[address]
+	self.mu.Unlock()
+	if again {
+		wake(self.accountCh)
+	}
+}
+
+// never blocks, signals not taken yet are merged
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -14,9 +14,12 @@ type snapshotPool struct {
 	BCPool
 	rwMu *sync.RWMutex
 	//consensus consensus.AccountsConsensus
-	closed chan struct{}
-	wg     sync.WaitGroup
-	pool   *pool
+	closed     chan struct{}
+	wg         sync.WaitGroup
+	pool       *pool
+	forkChoice ForkChoice
+	// chains of pool are modified by work and checkFork
+	workMu sync.Mutex
 }
 
 func newSnapshotPool(name string, v *version.Version) *snapshotPool {
@@ -31,10 +34,12 @@ func (self *snapshotPool) init(rw chainRw,
 	verifier verifier.Verifier,
 	syncer *fetcher,
 	rwMu *sync.RWMutex,
-	pool *pool) {
+	pool *pool,
+	forkChoice ForkChoice) {
 	self.rwMu = rwMu
 	//self.consensus = accountsConsensus
 	self.pool = pool
+	self.forkChoice = forkChoice
 	self.BCPool.init(rw, verifier, syncer)
 }
 
@@ -53,13 +58,14 @@ func (self *snapshotPool) loopCheckFork() {
 }
 
 func (self *snapshotPool) checkFork() {
-	longest := self.LongestChain()
+	self.workMu.Lock()
+	defer self.workMu.Unlock()
 	current := self.CurrentChain()
-	if longest.ChainId() == current.ChainId() {
+	chosen := self.forkChoice.Choose(current, self.acceptableChains())
+	if chosen.ChainId() == current.ChainId() {
 		return
 	}
-	self.snapshotFork(longest, current)
-
+	self.snapshotFork(chosen, current)
 }
 
 func (self *snapshotPool) snapshotFork(longest Chain, current Chain) {
@@ -76,47 +82,82 @@ func (self *snapshotPool) snapshotFork(longest Chain, current Chain) {
 	forkPoint := f.(*common.SnapshotBlock)
 	keyPoint := k.(*common.SnapshotBlock)
 
-	startAcs, endAcs := self.getUnlockAccountSnapshot(forkPoint)
-
-	err = self.pool.UnLockAccounts(startAcs, endAcs)
-	if err != nil {
-		log.Error("unlock accounts fail. err:%v", err)
+	// refuse before anything changes
+	finalized := self.pool.bc.FinalizedSnapshot()
+	if forkPoint.Height() < finalized.Height() {
+		log.Error("snapshot fork point[%d][%s] is below finalized block[%d][%s], longest chain:%s is refused.",
+			forkPoint.Height(), forkPoint.Hash(), finalized.Height(), finalized.Hash(), longest.ChainId())
+		self.refuse(longest.ChainId())
 		return
 	}
-	err = self.pool.ForkAccounts(keyPoint, forkPoint)
+	err = self.checkReorg(longest.ChainId(), forkPoint.Height())
 	if err != nil {
-		log.Error("rollback accounts fail. err:%v", err)
+		log.Error("snapshot fork is refused. err:%v", err)
 		return
 	}
+
+	reorg := &common.Reorg{Time: time.Now(), ForkPoint: common.HashHeight{Hash: forkPoint.Hash(), Height: forkPoint.Height()}}
+	for h := self.chainpool.diskChain.Head().Height(); h > forkPoint.Height(); h-- {
+		w := self.chainpool.diskChain.getBlock(h, false)
+		if w != nil {
+			reorg.Removed = append(reorg.Removed, common.HashHeight{Hash: w.block.Hash(), Height: h})
+		}
+	}
+	for h := forkPoint.Height() + 1; h <= longest.HeadHeight(); h++ {
+		block := longest.GetBlock(h)
+		if block != nil {
+			reorg.Added = append(reorg.Added, common.HashHeight{Hash: block.Hash(), Height: h})
+		}
+	}
+
+	// snapshot points are removed with snapshot blocks, which unlocks the accounts snapshotted by them
 	err = self.Rollback(forkPoint.Height(), forkPoint.Hash())
 	if err != nil {
 		log.Error("rollback snapshot fail. err:%v", err)
 		return
 	}
+	reorg.Accounts, err = self.pool.ForkAccounts(keyPoint, forkPoint)
+	if err != nil {
+		log.Error("rollback accounts fail. err:%v", err)
+		return
+	}
 	err = self.CurrentModifyToChain(longest)
 	if err != nil {
 		log.Error("snapshot modify current fail. err:%v", err)
 		return
 	}
 	self.version.Inc()
+	self.pool.bc.PutReorg(reorg)
+	log.Warn("snapshot chain reorg. fork point:%d, removed:%d, added:%d, accounts:%d.",
+		forkPoint.Height(), len(reorg.Removed), len(reorg.Added), len(reorg.Accounts))
 }
 
+// woken up by new snapshot blocks and account insertions, retried at retryInterval.
 func (self *snapshotPool) loop() {
 	defer self.wg.Done()
+	t := time.NewTicker(retryInterval)
+	defer t.Stop()
 	for {
 		select {
 		case <-self.closed:
 			return
-		default:
-			self.loopGenSnippetChains()
-			self.loopAppendChains()
-			self.loopFetchForSnippets()
-			self.loopCheckCurrentInsert()
-			time.Sleep(200 * time.Millisecond)
+		case <-self.pool.signals.snapshotCh:
+			self.work()
+		case <-t.C:
+			self.work()
 		}
 	}
 }
 
+func (self *snapshotPool) work() {
+	self.workMu.Lock()
+	defer self.workMu.Unlock()
+	self.loopGenSnippetChains()
+	self.loopAppendChains()
+	self.loopFetchForSnippets()
+	self.loopCheckCurrentInsert()
+}
+
 func (self *snapshotPool) loopCheckCurrentInsert() {
 	if self.chainpool.current.size() == 0 {
 		return
@@ -211,32 +252,3 @@ func (self *snapshotPool) insertVerifyPending(b common.Block, s verifier.BlockVe
 		}
 	}
 }
-
-func (self *snapshotPool) getUnlockAccountSnapshot(block *common.SnapshotBlock) (map[string]*common.SnapshotPoint, map[string]*common.SnapshotPoint) {
-	h := self.chainpool.diskChain.Head()
-	head := h.(*common.SnapshotBlock)
-	startAcs := make(map[string]*common.SnapshotPoint)
-	endAcs := make(map[string]*common.SnapshotPoint)
-
-	self.accounts(startAcs, endAcs, head)
-	for i := head.Height() - 1; i > block.Height(); i-- {
-		b := self.chainpool.diskChain.getBlock(i, false)
-		if b != nil {
-			block := b.block.(*common.SnapshotBlock)
-			self.accounts(startAcs, endAcs, block)
-		}
-	}
-	return startAcs, endAcs
-}
-
-func (self *snapshotPool) accounts(start map[string]*common.SnapshotPoint, end map[string]*common.SnapshotPoint, block *common.SnapshotBlock) {
-	hs := block.Accounts
-	for _, v := range hs {
-		point := &common.SnapshotPoint{SnapshotHeight: block.Height(), SnapshotHash: block.Hash(), AccountHeight: v.Height, AccountHash: v.Hash}
-		s := start[v.Addr]
-		if s == nil {
-			start[v.Addr] = point
-		}
-		end[v.Addr] = point
-	}
-}
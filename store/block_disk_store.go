@@ -0,0 +1,417 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/log"
+	"github.com/viteshan/naive-vite/store/db"
+)
+
+// key layout:
+//
+//	sh_{height}         -> snapshot hash
+//	s_{hash}            -> snapshot block
+//	ah_{addr}_{height}  -> account hash
+//	a_{hash}            -> account block
+//	hd_s                -> snapshot head
+//	hd_a_{addr}         -> account head
+//	hd_f                -> finalized snapshot block
+//	src_{hash}          -> received account block hash
+//	sp_{addr}/{height}  -> snapshot point, height is zero padded for ordering
+//	ev_{id}             -> evidence
+//	rg_{seq}            -> reorg, seq is zero padded for ordering
+//	hd_rg               -> seq of the last reorg
+const (
+	snapshotHeightPrefix = "sh_"
+	snapshotHashPrefix   = "s_"
+	accountHeightPrefix  = "ah_"
+	accountHashPrefix    = "a_"
+	snapshotHeadDiskKey  = "hd_s"
+	accountHeadPrefix    = "hd_a_"
+	finalizedDiskKey     = "hd_f"
+	sourceHashPrefix     = "src_"
+	snapshotPointPrefix  = "sp_"
+	evidencePrefix       = "ev_"
+	reorgPrefix          = "rg_"
+	reorgSeqDiskKey      = "hd_rg"
+)
+
+// block store persisted by db.DB
+type blockDiskStore struct {
+	db db.DB
+}
+
+func NewDiskStore(dir string) (BlockStore, error) {
+	d, err := db.NewLevelDB(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &blockDiskStore{db: d}, nil
+}
+
+// every single write is a batch of one operation
+func (self *blockDiskStore) PutSnapshot(block *common.SnapshotBlock) {
+	self.write(func(b Batch) { b.PutSnapshot(block) })
+}
+
+func (self *blockDiskStore) PutAccount(address string, block *common.AccountStateBlock) {
+	self.write(func(b Batch) { b.PutAccount(address, block) })
+}
+
+func (self *blockDiskStore) DeleteSnapshot(hashH common.HashHeight) {
+	self.write(func(b Batch) { b.DeleteSnapshot(hashH) })
+}
+
+func (self *blockDiskStore) DeleteAccount(address string, hashH common.HashHeight) {
+	self.write(func(b Batch) { b.DeleteAccount(address, hashH) })
+}
+
+func (self *blockDiskStore) SetSnapshotHead(hashH *common.HashHeight) {
+	self.write(func(b Batch) { b.SetSnapshotHead(hashH) })
+}
+
+func (self *blockDiskStore) SetFinalized(hashH *common.HashHeight) {
+	self.write(func(b Batch) { b.SetFinalized(hashH) })
+}
+
+func (self *blockDiskStore) SetAccountHead(address string, hashH *common.HashHeight) {
+	self.write(func(b Batch) { b.SetAccountHead(address, hashH) })
+}
+
+func (self *blockDiskStore) PutSourceHash(hash string, block *common.AccountStateBlock) {
+	self.write(func(b Batch) { b.PutSourceHash(hash, block) })
+}
+
+func (self *blockDiskStore) DeleteSourceHash(hash string) {
+	self.write(func(b Batch) { b.DeleteSourceHash(hash) })
+}
+
+func (self *blockDiskStore) PutSnapshotPoint(address string, point *common.SnapshotPoint) {
+	self.write(func(b Batch) { b.PutSnapshotPoint(address, point) })
+}
+
+func (self *blockDiskStore) DeleteSnapshotPoint(address string, snapshotHeight int) {
+	self.write(func(b Batch) { b.DeleteSnapshotPoint(address, snapshotHeight) })
+}
+
+func (self *blockDiskStore) PutEvidence(e *common.Evidence) {
+	self.write(func(b Batch) { b.PutEvidence(e) })
+}
+
+func (self *blockDiskStore) PutReorg(r *common.Reorg) {
+	self.write(func(b Batch) { b.PutReorg(r) })
+}
+
+func (self *blockDiskStore) DeleteReorg(seq int) {
+	self.write(func(b Batch) { b.DeleteReorg(seq) })
+}
+
+func (self *blockDiskStore) NewBatch() Batch {
+	return &diskBatch{db: self.db, b: self.db.NewBatch()}
+}
+
+func (self *blockDiskStore) write(fn func(b Batch)) {
+	batch := self.NewBatch()
+	fn(batch)
+	err := batch.Write()
+	if err != nil {
+		log.Error("write to db fail. err:%v", err)
+	}
+}
+
+func (self *blockDiskStore) GetSnapshotHead() *common.HashHeight {
+	hashH := &common.HashHeight{}
+	if !self.getJson([]byte(snapshotHeadDiskKey), hashH) {
+		return nil
+	}
+	return hashH
+}
+
+func (self *blockDiskStore) GetFinalized() *common.HashHeight {
+	hashH := &common.HashHeight{}
+	if !self.getJson([]byte(finalizedDiskKey), hashH) {
+		return nil
+	}
+	return hashH
+}
+
+func (self *blockDiskStore) GetAccountHead(address string) *common.HashHeight {
+	hashH := &common.HashHeight{}
+	if !self.getJson(accountHeadKey(address), hashH) {
+		return nil
+	}
+	return hashH
+}
+
+func (self *blockDiskStore) GetSnapshotByHash(hash string) *common.SnapshotBlock {
+	block := &common.SnapshotBlock{}
+	if !self.getJson(snapshotHashKey(hash), block) {
+		return nil
+	}
+	return block
+}
+
+func (self *blockDiskStore) GetSnapshotByHeight(height int) *common.SnapshotBlock {
+	hash := self.get(snapshotHeightKey(height))
+	if hash == nil {
+		return nil
+	}
+	return self.GetSnapshotByHash(string(hash))
+}
+
+func (self *blockDiskStore) GetAccountByHash(hash string) *common.AccountStateBlock {
+	block := &common.AccountStateBlock{}
+	if !self.getJson(accountHashKey(hash), block) {
+		return nil
+	}
+	return block
+}
+
+func (self *blockDiskStore) GetAccountByHeight(address string, height int) *common.AccountStateBlock {
+	hash := self.get(accountHeightKey(address, height))
+	if hash == nil {
+		return nil
+	}
+	return self.GetAccountByHash(string(hash))
+}
+
+func (self *blockDiskStore) GetAccountBySourceHash(hash string) *common.AccountStateBlock {
+	received := self.get(sourceHashKey(hash))
+	if received == nil {
+		return nil
+	}
+	return self.GetAccountByHash(string(received))
+}
+
+func (self *blockDiskStore) GetSnapshotPoints(address string, fromSnapshotHeight int) []*common.SnapshotPoint {
+	var result []*common.SnapshotPoint
+	from := string(snapshotPointKey(address, fromSnapshotHeight))
+	err := self.db.PrefixIterate(snapshotPointAddrPrefix(address), func(key []byte, val []byte) bool {
+		if string(key) < from {
+			return true
+		}
+		point := &common.SnapshotPoint{}
+		err := json.Unmarshal(val, point)
+		if err != nil {
+			log.Error("unmarshal fail. key:%s, err:%v", string(key), err)
+			return true
+		}
+		result = append(result, point)
+		return true
+	})
+	if err != nil {
+		log.Error("iterate snapshot points fail. address:%s, err:%v", address, err)
+	}
+	return result
+}
+
+func (self *blockDiskStore) GetEvidence(id string) *common.Evidence {
+	e := &common.Evidence{}
+	if !self.getJson(evidenceKey(id), e) {
+		return nil
+	}
+	return e
+}
+
+func (self *blockDiskStore) ListEvidence() []*common.Evidence {
+	var result []*common.Evidence
+	err := self.db.PrefixIterate([]byte(evidencePrefix), func(key []byte, val []byte) bool {
+		e := &common.Evidence{}
+		if err := json.Unmarshal(val, e); err != nil {
+			log.Error("unmarshal evidence fail. key:%s, err:%v", string(key), err)
+			return true
+		}
+		result = append(result, e)
+		return true
+	})
+	if err != nil {
+		log.Error("iterate evidences fail. err:%v", err)
+	}
+	return result
+}
+
+func (self *blockDiskStore) GetReorgSeq() int {
+	seq := 0
+	if !self.getJson([]byte(reorgSeqDiskKey), &seq) {
+		return 0
+	}
+	return seq
+}
+
+func (self *blockDiskStore) ListReorg() []*common.Reorg {
+	var result []*common.Reorg
+	err := self.db.PrefixIterate([]byte(reorgPrefix), func(key []byte, val []byte) bool {
+		r := &common.Reorg{}
+		if err := json.Unmarshal(val, r); err != nil {
+			log.Error("unmarshal reorg fail. key:%s, err:%v", string(key), err)
+			return true
+		}
+		result = append(result, r)
+		return true
+	})
+	if err != nil {
+		log.Error("iterate reorgs fail. err:%v", err)
+	}
+	return result
+}
+
+func (self *blockDiskStore) Close() error {
+	return self.db.Close()
+}
+
+func (self *blockDiskStore) get(key []byte) []byte {
+	val, err := self.db.Get(key)
+	if err != nil {
+		if err != db.ErrNotFound {
+			log.Error("get from db fail. key:%s, err:%v", string(key), err)
+		}
+		return nil
+	}
+	return val
+}
+
+func (self *blockDiskStore) getJson(key []byte, v interface{}) bool {
+	val := self.get(key)
+	if val == nil {
+		return false
+	}
+	err := json.Unmarshal(val, v)
+	if err != nil {
+		log.Error("unmarshal fail. key:%s, err:%v", string(key), err)
+		return false
+	}
+	return true
+}
+
+func snapshotHeightKey(height int) []byte {
+	return []byte(snapshotHeightPrefix + strconv.Itoa(height))
+}
+
+func snapshotHashKey(hash string) []byte {
+	return []byte(snapshotHashPrefix + hash)
+}
+
+func accountHeightKey(address string, height int) []byte {
+	return []byte(accountHeightPrefix + address + "_" + strconv.Itoa(height))
+}
+
+func accountHashKey(hash string) []byte {
+	return []byte(accountHashPrefix + hash)
+}
+
+func accountHeadKey(address string) []byte {
+	return []byte(accountHeadPrefix + address)
+}
+
+func sourceHashKey(hash string) []byte {
+	return []byte(sourceHashPrefix + hash)
+}
+
+func snapshotPointAddrPrefix(address string) []byte {
+	return []byte(snapshotPointPrefix + address + "/")
+}
+
+func snapshotPointKey(address string, snapshotHeight int) []byte {
+	return []byte(fmt.Sprintf("%s%s/%012d", snapshotPointPrefix, address, snapshotHeight))
+}
+
+func evidenceKey(id string) []byte {
+	return []byte(evidencePrefix + id)
+}
+
+func reorgKey(seq int) []byte {
+	return []byte(fmt.Sprintf("%s%012d", reorgPrefix, seq))
+}
+
+type diskBatch struct {
+	db db.DB
+	b  db.Batch
+}
+
+func (self *diskBatch) PutSnapshot(block *common.SnapshotBlock) {
+	self.putJson(snapshotHashKey(block.Hash()), block)
+	self.b.Put(snapshotHeightKey(block.Height()), []byte(block.Hash()))
+}
+
+func (self *diskBatch) PutAccount(address string, block *common.AccountStateBlock) {
+	self.putJson(accountHashKey(block.Hash()), block)
+	self.b.Put(accountHeightKey(address, block.Height()), []byte(block.Hash()))
+}
+
+func (self *diskBatch) DeleteSnapshot(hashH common.HashHeight) {
+	self.b.Del(snapshotHeightKey(hashH.Height))
+	self.b.Del(snapshotHashKey(hashH.Hash))
+}
+
+func (self *diskBatch) DeleteAccount(address string, hashH common.HashHeight) {
+	self.b.Del(accountHashKey(hashH.Hash))
+	self.b.Del(accountHeightKey(address, hashH.Height))
+}
+
+func (self *diskBatch) SetSnapshotHead(hashH *common.HashHeight) {
+	if hashH == nil {
+		self.b.Del([]byte(snapshotHeadDiskKey))
+	} else {
+		self.putJson([]byte(snapshotHeadDiskKey), hashH)
+	}
+}
+
+func (self *diskBatch) SetFinalized(hashH *common.HashHeight) {
+	self.putJson([]byte(finalizedDiskKey), hashH)
+}
+
+func (self *diskBatch) SetAccountHead(address string, hashH *common.HashHeight) {
+	if hashH == nil {
+		self.b.Del(accountHeadKey(address))
+	} else {
+		self.putJson(accountHeadKey(address), hashH)
+	}
+}
+
+func (self *diskBatch) PutSourceHash(hash string, block *common.AccountStateBlock) {
+	self.b.Put(sourceHashKey(hash), []byte(block.Hash()))
+}
+
+func (self *diskBatch) DeleteSourceHash(hash string) {
+	self.b.Del(sourceHashKey(hash))
+}
+
+func (self *diskBatch) PutSnapshotPoint(address string, point *common.SnapshotPoint) {
+	self.putJson(snapshotPointKey(address, point.SnapshotHeight), point)
+}
+
+func (self *diskBatch) DeleteSnapshotPoint(address string, snapshotHeight int) {
+	self.b.Del(snapshotPointKey(address, snapshotHeight))
+}
+
+func (self *diskBatch) PutEvidence(e *common.Evidence) {
+	self.putJson(evidenceKey(e.Id()), e)
+}
+
+func (self *diskBatch) PutReorg(r *common.Reorg) {
+	self.putJson(reorgKey(r.Seq), r)
+	self.putJson([]byte(reorgSeqDiskKey), r.Seq)
+}
+
+func (self *diskBatch) DeleteReorg(seq int) {
+	self.b.Del(reorgKey(seq))
+}
+
+func (self *diskBatch) Write() error {
+	if self.b.Len() == 0 {
+		return nil
+	}
+	return self.db.Write(self.b)
+}
+
+func (self *diskBatch) putJson(key []byte, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		// the block can't be encoded, write nothing rather than a part of batch
+		panic("marshal fail. key:" + string(key) + ", err:" + err.Error())
+	}
+	self.b.Put(key, bytes)
+}
@@ -1,17 +1,15 @@
 package store
 
 import (
+	"sort"
 	"sync"
 
 	"strconv"
 
-	"time"
-
 	"github.com/viteshan/naive-vite/common"
-	"github.com/viteshan/naive-vite/tools"
 )
 
-type BlockStore interface {
+type BlockWriter interface {
 	PutSnapshot(block *common.SnapshotBlock)
 	PutAccount(address string, block *common.AccountStateBlock)
 	DeleteSnapshot(hashH common.HashHeight)
@@ -19,9 +17,34 @@ type BlockStore interface {
 
 	SetSnapshotHead(hashH *common.HashHeight)
 	SetAccountHead(address string, hashH *common.HashHeight)
+	// the highest irreversible snapshot block
+	SetFinalized(hashH *common.HashHeight)
+
+	PutSourceHash(hash string, block *common.AccountStateBlock)
+	DeleteSourceHash(hash string)
+
+	PutSnapshotPoint(address string, point *common.SnapshotPoint)
+	DeleteSnapshotPoint(address string, snapshotHeight int)
+
+	PutEvidence(e *common.Evidence)
+	// seq of the last reorg is kept with it
+	PutReorg(r *common.Reorg)
+	DeleteReorg(seq int)
+}
+
+// Batch buffers writes, Write applies all of them or none of them.
+type Batch interface {
+	BlockWriter
+	Write() error
+}
+
+type BlockStore interface {
+	BlockWriter
 
 	GetSnapshotHead() *common.HashHeight
 	GetAccountHead(address string) *common.HashHeight
+	// nil if nothing is finalized after genesis
+	GetFinalized() *common.HashHeight
 
 	GetSnapshotByHash(hash string) *common.SnapshotBlock
 	GetSnapshotByHeight(height int) *common.SnapshotBlock
@@ -30,25 +53,28 @@ type BlockStore interface {
 	GetAccountByHeight(address string, height int) *common.AccountStateBlock
 
 	GetAccountBySourceHash(hash string) *common.AccountStateBlock
-	PutSourceHash(hash string, block *common.AccountStateBlock)
-	DeleteSourceHash(hash string)
+
+	// snapshot points of address whose snapshot height >= fromSnapshotHeight, order by snapshot height
+	GetSnapshotPoints(address string, fromSnapshotHeight int) []*common.SnapshotPoint
+
+	GetEvidence(id string) *common.Evidence
+	// evidences order by id
+	ListEvidence() []*common.Evidence
+	// reorgs order by seq
+	ListReorg() []*common.Reorg
+	// seq of the last reorg, 0 if nothing is journaled
+	GetReorgSeq() int
+
+	NewBatch() Batch
+	Close() error
 }
 
-func NewMemoryStore(snapshotGenesis *common.SnapshotBlock) BlockStore {
+func NewMemoryStore() BlockStore {
 	self := &blockMemoryStore{}
-	for _, a := range genesisAccounts {
-		genesis := common.NewAccountBlock(0, "", "", a, time.Unix(0, 0),
-			200, 0, 0, "", common.GENESIS, a, a, "", -1)
-		genesis.SetHash(tools.CalculateAccountHash(genesis))
-		self.PutAccount(a, genesis)
-		self.SetAccountHead(a, &common.HashHeight{Hash: genesis.Hash(), Height: genesis.Height()})
-	}
-
+	self.points = make(map[string][]*common.SnapshotPoint)
 	return self
 }
 
-var genesisAccounts = []string{"viteshan", "jie"}
-
 // thread safe block memory store
 type blockMemoryStore struct {
 	snapshotHeight sync.Map
@@ -60,11 +86,23 @@ type blockMemoryStore struct {
 
 	head sync.Map
 
+	// key: address val: points order by snapshot height
+	points map[string][]*common.SnapshotPoint
+	pMu    sync.RWMutex
+
+	// key: evidence id
+	evidences sync.Map
+	// key: reorg seq
+	reorgs sync.Map
+
 	sMu sync.Mutex
 	aMu sync.Mutex
+	wMu sync.Mutex // batch write
 }
 
 var snapshotHeadKey = "s_head_key"
+var finalizedKey = "f_head_key"
+var reorgSeqKey = "rg_head_key"
 
 func (self *blockMemoryStore) GetSnapshotHead() *common.HashHeight {
 	value, ok := self.head.Load(snapshotHeadKey)
@@ -90,6 +128,18 @@ func (self *blockMemoryStore) SetSnapshotHead(hashH *common.HashHeight) {
 	}
 }
 
+func (self *blockMemoryStore) GetFinalized() *common.HashHeight {
+	value, ok := self.head.Load(finalizedKey)
+	if !ok {
+		return nil
+	}
+	return value.(*common.HashHeight)
+}
+
+func (self *blockMemoryStore) SetFinalized(hashH *common.HashHeight) {
+	self.head.Store(finalizedKey, hashH)
+}
+
 func (self *blockMemoryStore) SetAccountHead(address string, hashH *common.HashHeight) {
 	if hashH == nil {
 		self.head.Delete(address)
@@ -167,6 +217,177 @@ func (self *blockMemoryStore) GetAccountByHeight(address string, height int) *co
 
 }
 
+func (self *blockMemoryStore) PutSnapshotPoint(address string, point *common.SnapshotPoint) {
+	self.pMu.Lock()
+	defer self.pMu.Unlock()
+	points := self.points[address]
+	i := sort.Search(len(points), func(i int) bool { return points[i].SnapshotHeight >= point.SnapshotHeight })
+	if i < len(points) && points[i].SnapshotHeight == point.SnapshotHeight {
+		points[i] = point
+		return
+	}
+	points = append(points, nil)
+	copy(points[i+1:], points[i:])
+	points[i] = point
+	self.points[address] = points
+}
+
+func (self *blockMemoryStore) DeleteSnapshotPoint(address string, snapshotHeight int) {
+	self.pMu.Lock()
+	defer self.pMu.Unlock()
+	points := self.points[address]
+	for i, p := range points {
+		if p.SnapshotHeight == snapshotHeight {
+			self.points[address] = append(points[:i:i], points[i+1:]...)
+			return
+		}
+	}
+}
+
+func (self *blockMemoryStore) GetSnapshotPoints(address string, fromSnapshotHeight int) []*common.SnapshotPoint {
+	self.pMu.RLock()
+	defer self.pMu.RUnlock()
+	var result []*common.SnapshotPoint
+	for _, p := range self.points[address] {
+		if p.SnapshotHeight >= fromSnapshotHeight {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (self *blockMemoryStore) PutEvidence(e *common.Evidence) {
+	self.evidences.Store(e.Id(), e)
+}
+
+func (self *blockMemoryStore) GetEvidence(id string) *common.Evidence {
+	value, ok := self.evidences.Load(id)
+	if !ok {
+		return nil
+	}
+	return value.(*common.Evidence)
+}
+
+func (self *blockMemoryStore) ListEvidence() []*common.Evidence {
+	var result []*common.Evidence
+	self.evidences.Range(func(k, v interface{}) bool {
+		result = append(result, v.(*common.Evidence))
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id() < result[j].Id()
+	})
+	return result
+}
+
+func (self *blockMemoryStore) PutReorg(r *common.Reorg) {
+	self.reorgs.Store(r.Seq, r)
+	self.head.Store(reorgSeqKey, r.Seq)
+}
+
+func (self *blockMemoryStore) DeleteReorg(seq int) {
+	self.reorgs.Delete(seq)
+}
+
+func (self *blockMemoryStore) GetReorgSeq() int {
+	value, ok := self.head.Load(reorgSeqKey)
+	if !ok {
+		return 0
+	}
+	return value.(int)
+}
+
+func (self *blockMemoryStore) ListReorg() []*common.Reorg {
+	var result []*common.Reorg
+	self.reorgs.Range(func(k, v interface{}) bool {
+		result = append(result, v.(*common.Reorg))
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Seq < result[j].Seq
+	})
+	return result
+}
+
+func (self *blockMemoryStore) NewBatch() Batch {
+	return &memoryBatch{store: self}
+}
+
+func (self *blockMemoryStore) Close() error {
+	return nil
+}
+
 func (self *blockMemoryStore) genKey(address string, height int) string {
 	return address + "_" + strconv.Itoa(height)
 }
+
+type memoryBatch struct {
+	store *blockMemoryStore
+	ops   []func(w BlockWriter)
+}
+
+func (self *memoryBatch) PutSnapshot(block *common.SnapshotBlock) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutSnapshot(block) })
+}
+
+func (self *memoryBatch) PutAccount(address string, block *common.AccountStateBlock) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutAccount(address, block) })
+}
+
+func (self *memoryBatch) DeleteSnapshot(hashH common.HashHeight) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.DeleteSnapshot(hashH) })
+}
+
+func (self *memoryBatch) DeleteAccount(address string, hashH common.HashHeight) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.DeleteAccount(address, hashH) })
+}
+
+func (self *memoryBatch) SetSnapshotHead(hashH *common.HashHeight) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.SetSnapshotHead(hashH) })
+}
+
+func (self *memoryBatch) SetFinalized(hashH *common.HashHeight) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.SetFinalized(hashH) })
+}
+
+func (self *memoryBatch) SetAccountHead(address string, hashH *common.HashHeight) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.SetAccountHead(address, hashH) })
+}
+
+func (self *memoryBatch) PutSourceHash(hash string, block *common.AccountStateBlock) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutSourceHash(hash, block) })
+}
+
+func (self *memoryBatch) DeleteSourceHash(hash string) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.DeleteSourceHash(hash) })
+}
+
+func (self *memoryBatch) PutSnapshotPoint(address string, point *common.SnapshotPoint) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutSnapshotPoint(address, point) })
+}
+
+func (self *memoryBatch) DeleteSnapshotPoint(address string, snapshotHeight int) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.DeleteSnapshotPoint(address, snapshotHeight) })
+}
+
+func (self *memoryBatch) PutEvidence(e *common.Evidence) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutEvidence(e) })
+}
+
+func (self *memoryBatch) PutReorg(r *common.Reorg) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.PutReorg(r) })
+}
+
+func (self *memoryBatch) DeleteReorg(seq int) {
+	self.ops = append(self.ops, func(w BlockWriter) { w.DeleteReorg(seq) })
+}
+
+func (self *memoryBatch) Write() error {
+	self.store.wMu.Lock()
+	defer self.store.wMu.Unlock()
+	for _, op := range self.ops {
+		op(self.store)
+	}
+	self.ops = nil
+	return nil
+}
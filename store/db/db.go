@@ -1,7 +1,26 @@
 package db
 
+import "errors"
+
+var ErrNotFound = errors.New("db: not found")
+
 type DB interface {
+	Put(key []byte, val []byte) error
+	// return ErrNotFound if key not exist
+	Get(key []byte) ([]byte, error)
+	Del(key []byte) error
+	// iterate keys with prefix in ascending order, stop if fn return false
+	PrefixIterate(prefix []byte, fn func(key []byte, val []byte) bool) error
+
+	NewBatch() Batch
+	// write all operations of batch atomically
+	Write(batch Batch) error
+	Close() error
+}
+
+// operations are buffered until DB.Write
+type Batch interface {
 	Put(key []byte, val []byte)
-	Get(key []byte)
 	Del(key []byte)
+	Len() int
 }
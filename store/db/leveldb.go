@@ -0,0 +1,76 @@
+package db
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// embedded kv store, one directory per node
+type levelDB struct {
+	db *leveldb.DB
+}
+
+func NewLevelDB(dir string) (DB, error) {
+	d, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDB{db: d}, nil
+}
+
+func (self *levelDB) Put(key []byte, val []byte) error {
+	return self.db.Put(key, val, nil)
+}
+
+func (self *levelDB) Get(key []byte) ([]byte, error) {
+	val, err := self.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return val, err
+}
+
+func (self *levelDB) Del(key []byte) error {
+	return self.db.Delete(key, nil)
+}
+
+func (self *levelDB) PrefixIterate(prefix []byte, fn func(key []byte, val []byte) bool) error {
+	iter := self.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func (self *levelDB) NewBatch() Batch {
+	return &levelBatch{b: new(leveldb.Batch)}
+}
+
+func (self *levelDB) Write(batch Batch) error {
+	// sync to disk, a batch must survive a crash as a whole
+	return self.db.Write(batch.(*levelBatch).b, &opt.WriteOptions{Sync: true})
+}
+
+func (self *levelDB) Close() error {
+	return self.db.Close()
+}
+
+type levelBatch struct {
+	b *leveldb.Batch
+}
+
+func (self *levelBatch) Put(key []byte, val []byte) {
+	self.b.Put(key, val)
+}
+
+func (self *levelBatch) Del(key []byte) {
+	self.b.Delete(key)
+}
+
+func (self *levelBatch) Len() int {
+	return self.b.Len()
+}
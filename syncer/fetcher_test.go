@@ -25,6 +25,10 @@ func (self *senderTest) BroadcastSnapshotBlocks(blocks []*common.SnapshotBlock)
 	panic("implement BroadcastSnapshotBlocks")
 }
 
+func (self *senderTest) BroadcastEvidences(evidences []*common.Evidence) error {
+	panic("implement BroadcastEvidences")
+}
+
 func (self *senderTest) SendAccountBlocks(address string, blocks []*common.AccountStateBlock, peer p2p.Peer) error {
 	panic("implement SendAccountBlocks")
 }
@@ -32,6 +32,7 @@ func newReceiver(fetcher *fetcher, rw *chainRw, sender Sender, s *state) *receiv
 	innerhandlers = append(innerhandlers, &snapshotHashHandler{fetcher: fetcher})
 	innerhandlers = append(innerhandlers, &snapshotBlocksHandler{sWriter: rw, fetcher: fetcher})
 	innerhandlers = append(innerhandlers, &accountBlocksHandler{aWriter: rw, fetcher: fetcher})
+	innerhandlers = append(innerhandlers, &evidencesHandler{writer: rw})
 	innerhandlers = append(innerhandlers, &stateHandler{state: s})
 	innerhandlers = append(innerhandlers, &reqAccountHashHandler{aReader: rw, sender: sender})
 	innerhandlers = append(innerhandlers, &reqSnapshotHashHandler{sReader: rw, sender: sender})
@@ -186,6 +187,34 @@ func (self *accountBlocksHandler) Id() string {
 	return "default-accountBlocksHandler"
 }
 
+type evidencesHandler struct {
+	MsgHandler
+	writer *chainRw
+}
+
+func (self *evidencesHandler) Types() []common.NetMsgType {
+	return []common.NetMsgType{common.Evidences}
+}
+
+func (self *evidencesHandler) Handle(t common.NetMsgType, msg []byte, peer p2p.Peer) {
+	evidencesMsg := &evidencesMsg{}
+	err := json.Unmarshal(msg, evidencesMsg)
+	if err != nil {
+		log.Error("evidencesHandler.Handle unmarshal fail.")
+		return
+	}
+	for _, e := range evidencesMsg.Evidences {
+		err := self.writer.AddEvidence(e)
+		if err != nil {
+			log.Warn("evidence from peer[%s] is invalid. err:%v", peer.Id(), err)
+		}
+	}
+}
+
+func (self *evidencesHandler) Id() string {
+	return "default-evidencesHandler"
+}
+
 func (self *receiver) Handle(t common.NetMsgType, msg []byte, peer p2p.Peer) {
 	self.innerHandle(t, msg, peer, self.innerHandlers)
 	self.handle(t, msg, peer, self.handlers)
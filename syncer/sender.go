@@ -94,6 +94,32 @@ func (self *sender) BroadcastSnapshotBlocks(blocks []*common.SnapshotBlock) erro
 	return err
 }
 
+func (self *sender) BroadcastEvidences(evidences []*common.Evidence) error {
+	bytM, err := json.Marshal(&evidencesMsg{Evidences: evidences})
+	msg := p2p.NewMsg(common.Evidences, bytM)
+
+	if err != nil {
+		return errors.New("BroadcastEvidences, format fail. err:" + err.Error())
+	}
+	peers, err := self.net.AllPeer()
+	if err != nil {
+		log.Error("BroadcastEvidences, can't get all peer.%v", err)
+		return err
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+
+	for _, p := range peers {
+		tmpE := p.Write(msg)
+		if tmpE != nil {
+			err = tmpE
+			log.Error("BroadcastEvidences, write data fail, peerId:%s, err:%v", p.Id(), err)
+		}
+	}
+	return err
+}
+
 func (self *sender) SendAccountBlocks(address string, blocks []*common.AccountStateBlock, peer p2p.Peer) error {
 	bytM, err := json.Marshal(&accountBlocksMsg{Address: address, Blocks: blocks})
 	if err != nil {
@@ -1,6 +1,7 @@
 package syncer
 
 import (
+	"sort"
 	"sync"
 
 	"time"
@@ -37,6 +38,8 @@ type state struct {
 	firstTa *syncTask
 	p       p2p.P2P
 	bus     EventBus.Bus
+	// guard heights of peer states
+	heightMu sync.RWMutex
 }
 
 type handState struct {
@@ -134,8 +137,10 @@ func (self *state) update(msg *stateMsg, peer p2p.Peer) {
 		log.Error("peer state is empty.", peer.Id())
 	} else {
 		state := prevState.(*handState)
+		self.heightMu.Lock()
 		state.S.Height = msg.Height
 		state.S.Hash = msg.Hash
+		self.heightMu.Unlock()
 	}
 	head, e := self.rw.HeadSnapshot()
 	if e != nil {
@@ -182,6 +187,39 @@ func (self *state) syncDone() bool {
 	return self.firstTa.done > 0
 }
 
+func (self *state) behind() int {
+	head, e := self.rw.HeadSnapshot()
+	if e != nil {
+		log.Error("read snapshot head error:%v", e)
+		return 0
+	}
+	peers := self.peersHeight()
+	if peers <= head.Height() {
+		return 0
+	}
+	return peers - head.Height()
+}
+
+// the median snapshot height of connected peers, a single peer can't claim the node is behind.
+// the lower one of two middle heights is taken, -1 without peers
+func (self *state) peersHeight() int {
+	self.heightMu.RLock()
+	var heights []int
+	self.peers.Range(func(_, p interface{}) bool {
+		s, ok := p.(*syncPeer).peer.GetState().(*handState)
+		if ok {
+			heights = append(heights, s.S.Height)
+		}
+		return true
+	})
+	self.heightMu.RUnlock()
+	if len(heights) == 0 {
+		return -1
+	}
+	sort.Ints(heights)
+	return heights[(len(heights)-1)/2]
+}
+
 func (self *state) stop() {
 	close(self.closed)
 	self.wg.Wait()
@@ -21,6 +21,7 @@ type Syncer interface {
 	Start()
 	Stop()
 	Done() bool
+	Behind() int
 }
 type chainRw struct {
 	face.ChainReader
@@ -44,6 +45,8 @@ type Sender interface {
 	// when new block create
 	BroadcastAccountBlocks(string, []*common.AccountStateBlock) error
 	BroadcastSnapshotBlocks([]*common.SnapshotBlock) error
+	// when producer signs two blocks for one slot
+	BroadcastEvidences([]*common.Evidence) error
 
 	// when fetch block message be arrived
 	SendAccountBlocks(string, []*common.AccountStateBlock, p2p.Peer) error
@@ -119,3 +122,7 @@ func (self *syncer) Handlers() Handlers {
 func (self *syncer) Done() bool {
 	return self.state.syncDone()
 }
+
+func (self *syncer) Behind() int {
+	return self.state.behind()
+}
@@ -15,6 +15,10 @@ type snapshotBlocksMsg struct {
 	Blocks []*common.SnapshotBlock
 }
 
+type evidencesMsg struct {
+	Evidences []*common.Evidence
+}
+
 type accountHashesMsg struct {
 	Address string
 	Hashes  []common.HashHeight
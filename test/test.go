@@ -31,6 +31,12 @@ func (self *TestBlock) Signer() string {
 func (self *TestBlock) Timestamp() time.Time {
 	return self.Ttimestamp
 }
+func (self *TestBlock) Signature() []byte {
+	return nil
+}
+func (self *TestBlock) PubKey() []byte {
+	return nil
+}
 func (self *TestBlock) String() string {
 	return "Theight:[" + strconv.Itoa(self.Theight) + "]\tThash:[" + self.Thash + "]\tTpreHash:[" + self.TpreHash + "]\tTsigner:[" + self.Tsigner + "]"
 }
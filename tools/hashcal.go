@@ -26,7 +26,8 @@ func CalculateAccountHash(block *common.AccountStateBlock) string {
 		block.From +
 		block.To +
 		block.SourceHash +
-		strconv.Itoa(block.SourceHeight))
+		strconv.Itoa(block.SourceHeight) +
+		block.Data)
 }
 
 func blockStr(block common.Block) string {
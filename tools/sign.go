@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+type signable interface {
+	common.Block
+	SetSignature(signature []byte, pubKey []byte)
+}
+
+// sign the hash of block, hash must be set before
+func SignBlock(block signable, fn common.SignerFn) error {
+	if fn == nil {
+		return errors.New("signer is not set")
+	}
+	addr, err := common.ParseAddress(block.Signer())
+	if err != nil {
+		return err
+	}
+	signature, pubKey, err := fn(addr, []byte(block.Hash()))
+	if err != nil {
+		return err
+	}
+	block.SetSignature(signature, pubKey)
+	return nil
+}
+
+// check the signature of block hash, and signer must be the address of public key
+func VerifySignature(block common.Block) error {
+	if len(block.PubKey()) != ed25519.PublicKeySize {
+		return errors.New("public key is invalid")
+	}
+	if len(block.Signature()) != ed25519.SignatureSize {
+		return errors.New("signature is invalid")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(block.PubKey()), []byte(block.Hash()), block.Signature()) {
+		return errors.New("signature verify fail")
+	}
+	if common.PubKeyToAddress(block.PubKey()).String() != block.Signer() {
+		return errors.New("public key does not match signer")
+	}
+	return nil
+}
+
+// both blocks of evidence must be signed by the signer for the same slot, and must be different.
+// whether the signer owns the slot is up to consensus.
+func VerifyEvidence(e *common.Evidence) error {
+	if e == nil || e.First == nil || e.Second == nil {
+		return errors.New("evidence is incomplete")
+	}
+	if e.First.Signer() != e.Second.Signer() {
+		return errors.New("signers of evidence are different")
+	}
+	if !e.First.Timestamp().Equal(e.Second.Timestamp()) {
+		return errors.New("slots of evidence are different")
+	}
+	if e.First.Hash() == e.Second.Hash() {
+		return errors.New("blocks of evidence are the same")
+	}
+	for _, b := range []*common.SnapshotBlock{e.First, e.Second} {
+		if CalculateSnapshotHash(b) != b.Hash() {
+			return errors.New("hash of evidence block[" + b.Hash() + "] is invalid")
+		}
+		if err := VerifySignature(b); err != nil {
+			return errors.New("signature of evidence block[" + b.Hash() + "] is invalid, " + err.Error())
+		}
+	}
+	return nil
+}
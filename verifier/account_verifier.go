@@ -8,6 +8,7 @@ import (
 	"github.com/viteshan/naive-vite/common"
 	"github.com/viteshan/naive-vite/common/face"
 	"github.com/viteshan/naive-vite/monitor"
+	"github.com/viteshan/naive-vite/tools"
 	"github.com/viteshan/naive-vite/version"
 )
 
@@ -83,6 +84,25 @@ func (self *AccountVerifier) verifyFrom(block *common.AccountStateBlock, stat *A
 	return false
 }
 
+// block must be signed by the key of signer
+func (self *AccountVerifier) verifySignature(block *common.AccountStateBlock, stat *AccountBlockVerifyStat) bool {
+	defer monitor.LogTime("verify", "accountSignature", time.Now())
+	if block.Hash() != tools.CalculateAccountHash(block) {
+		stat.errMsg = fmt.Sprintf("block[%s][%d][%s] error, hash is invalid.",
+			block.Signer(), block.Height(), block.Hash())
+		stat.referredSelfResult = FAIL
+		return true
+	}
+	err := tools.VerifySignature(block)
+	if err != nil {
+		stat.errMsg = fmt.Sprintf("block[%s][%d][%s] error, %v.",
+			block.Signer(), block.Height(), block.Hash(), err)
+		stat.referredSelfResult = FAIL
+		return true
+	}
+	return false
+}
+
 func (self *AccountVerifier) verifySnapshot(block *common.AccountStateBlock, stat *AccountBlockVerifyStat) bool {
 	defer monitor.LogTime("verify", "accountSnapshot", time.Now())
 	// referred snapshot
@@ -112,6 +132,11 @@ func (self *AccountVerifier) VerifyReferred(b common.Block) BlockVerifyStat {
 		}
 	}
 
+	// check signature
+	if self.verifySignature(block, stat) {
+		return stat
+	}
+
 	// check snapshot
 	if self.verifySnapshot(block, stat) {
 		return stat
@@ -7,22 +7,41 @@ import (
 
 	"github.com/viteshan/naive-vite/common"
 	"github.com/viteshan/naive-vite/common/face"
+	"github.com/viteshan/naive-vite/consensus"
+	"github.com/viteshan/naive-vite/tools"
 	"github.com/viteshan/naive-vite/version"
 )
 
 type SnapshotVerifier struct {
-	reader face.ChainReader
-	v      *version.Version
+	reader      face.ChainReader
+	cv          consensus.ConsensusVerifier
+	v           *version.Version
+	maxAccounts int
 }
 
-func NewSnapshotVerifier(r face.ChainReader, v *version.Version) *SnapshotVerifier {
-	verifier := &SnapshotVerifier{reader: r, v: v}
+// if cv is nil, producer of block is not checked
+func NewSnapshotVerifier(r face.ChainReader, cv consensus.ConsensusVerifier, v *version.Version) *SnapshotVerifier {
+	verifier := &SnapshotVerifier{reader: r, cv: cv, v: v, maxAccounts: common.MaxSnapshotAccounts}
 	return verifier
 }
 
 func (self *SnapshotVerifier) VerifyReferred(b common.Block) BlockVerifyStat {
 	block := b.(*common.SnapshotBlock)
 	stat := self.newVerifyStat(VerifyReferred, block)
+	if !self.verifySignature(block, stat) {
+		stat.result = FAIL
+		return stat
+	}
+	if !self.verifyProducer(block, stat) {
+		stat.result = FAIL
+		return stat
+	}
+	if len(block.Accounts) > self.maxAccounts {
+		stat.errMsg = fmt.Sprintf("snapshot block[%s][%d][%s] error, %d accounts exceed max %d.",
+			block.Signer(), block.Height(), block.Hash(), len(block.Accounts), self.maxAccounts)
+		stat.result = FAIL
+		return stat
+	}
 	accounts := block.Accounts
 
 	task := &verifyTask{v: self.v, version: self.v.Val(), reader: self.reader, t: time.Now()}
@@ -57,6 +76,40 @@ func (self *SnapshotVerifier) VerifyReferred(b common.Block) BlockVerifyStat {
 	return stat
 }
 
+func (self *SnapshotVerifier) verifySignature(block *common.SnapshotBlock, stat *SnapshotBlockVerifyStat) bool {
+	if block.Hash() != tools.CalculateSnapshotHash(block) {
+		stat.errMsg = fmt.Sprintf("snapshot block[%s][%d][%s] error, hash is invalid.",
+			block.Signer(), block.Height(), block.Hash())
+		return false
+	}
+	err := tools.VerifySignature(block)
+	if err != nil {
+		stat.errMsg = fmt.Sprintf("snapshot block[%s][%d][%s] error, %v.",
+			block.Signer(), block.Height(), block.Hash(), err)
+		return false
+	}
+	return true
+}
+
+// signer must own the time slot of block
+func (self *SnapshotVerifier) verifyProducer(block *common.SnapshotBlock, stat *SnapshotBlockVerifyStat) bool {
+	if self.cv == nil {
+		return true
+	}
+	result, err := self.cv.Verify(self.reader, block)
+	if err != nil {
+		stat.errMsg = fmt.Sprintf("snapshot block[%s][%d][%s] error, %v.",
+			block.Signer(), block.Height(), block.Hash(), err)
+		return false
+	}
+	if !result {
+		stat.errMsg = fmt.Sprintf("snapshot block[%s][%d][%s] error, producer is invalid.",
+			block.Signer(), block.Height(), block.Hash())
+		return false
+	}
+	return true
+}
+
 type SnapshotBlockVerifyStat struct {
 	result   VerifyResult
 	accounts []*common.AccountHashH
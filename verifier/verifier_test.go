@@ -0,0 +1,168 @@
+package verifier
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/chain"
+	"github.com/viteshan/naive-vite/common"
+	"github.com/viteshan/naive-vite/common/clock"
+	"github.com/viteshan/naive-vite/common/config"
+	"github.com/viteshan/naive-vite/consensus"
+	"github.com/viteshan/naive-vite/tools"
+	"github.com/viteshan/naive-vite/version"
+	"github.com/viteshan/naive-vite/wallet"
+)
+
+func genSendBlock(bc chain.BlockChain, fn common.SignerFn, from string, amount int) *common.AccountStateBlock {
+	head, _ := bc.HeadAccount(from)
+	snapshot, _ := bc.HeadSnapshot()
+	block := common.NewAccountBlockFrom(head, from, time.Now(), amount, snapshot,
+		common.SEND, from, config.DevAddress(1).String(), "", -1)
+	block.SetHash(tools.CalculateAccountHash(block))
+	if fn != nil {
+		tools.SignBlock(block, fn)
+	}
+	return block
+}
+
+func genSnapshotBlock(bc chain.BlockChain, fn common.SignerFn, producer string) *common.SnapshotBlock {
+	head, _ := bc.HeadSnapshot()
+	return genSnapshotBlockAt(bc, fn, producer, time.Unix(head.Timestamp().Unix()+1, 0))
+}
+
+func genSnapshotBlockAt(bc chain.BlockChain, fn common.SignerFn, producer string, t time.Time) *common.SnapshotBlock {
+	head, _ := bc.HeadSnapshot()
+	block := common.NewSnapshotBlock(head.Height()+1, "", head.Hash(), producer, t, nil)
+	block.SetHash(tools.CalculateSnapshotHash(block))
+	if fn != nil {
+		tools.SignBlock(block, fn)
+	}
+	return block
+}
+
+func unlockedWallet(t *testing.T, key ed25519.PrivateKey) wallet.Wallet {
+	w, _ := wallet.NewWallet("")
+	addr, err := w.ImportKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(addr, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// sign by a key which doesn't belong to the signer
+func forgedSignerFn(a common.Address, data []byte) ([]byte, []byte, error) {
+	key := config.DevKey(100)
+	return ed25519.Sign(key, data), key.Public().(ed25519.PublicKey), nil
+}
+
+func TestAccountSignature(t *testing.T) {
+	bc := chain.NewChain("", config.DefaultGenesis())
+	v := NewAccountVerifier(bc, &version.Version{})
+	w := unlockedWallet(t, config.DevKey(0))
+	from := config.DevAddress(0).String()
+
+	block := genSendBlock(bc, w.Sign, from, -30)
+	stat := v.VerifyReferred(block)
+	if stat.VerifyResult() != SUCCESS {
+		t.Fatal("signed block should pass.", stat.ErrMsg())
+	}
+
+	unsigned := genSendBlock(bc, nil, from, -30)
+	if stat := v.VerifyReferred(unsigned); stat.VerifyResult() != FAIL {
+		t.Error("unsigned block should fail.")
+	}
+
+	modified := genSendBlock(bc, w.Sign, from, -30)
+	modified.ModifiedAmount = -10
+	modified.Amount = 190
+	if stat := v.VerifyReferred(modified); stat.VerifyResult() != FAIL {
+		t.Error("modified block should fail.")
+	}
+
+	forged := genSendBlock(bc, forgedSignerFn, from, -30)
+	if stat := v.VerifyReferred(forged); stat.VerifyResult() != FAIL {
+		t.Error("block signed by other key should fail.")
+	}
+}
+
+func TestSnapshotSignature(t *testing.T) {
+	bc := chain.NewChain("", config.DefaultGenesis())
+	v := NewSnapshotVerifier(bc, nil, &version.Version{})
+	w := unlockedWallet(t, config.DevKey(0))
+	producer := config.DevAddress(0).String()
+
+	if stat := v.VerifyReferred(genSnapshotBlock(bc, nil, producer)); stat.VerifyResult() != FAIL {
+		t.Error("unsigned block should fail.")
+	}
+	if stat := v.VerifyReferred(genSnapshotBlock(bc, forgedSignerFn, producer)); stat.VerifyResult() != FAIL {
+		t.Error("block signed by other key should fail.")
+	}
+	stat := v.VerifyReferred(genSnapshotBlock(bc, w.Sign, producer))
+	if stat.VerifyResult() != SUCCESS {
+		t.Error("signed block should pass.", stat.ErrMsg())
+	}
+}
+
+func TestSnapshotProducer(t *testing.T) {
+	genesis := config.DefaultGenesis()
+	genesisTime := time.Unix(genesis.Timestamp, 0)
+	bc := chain.NewChain("", genesis)
+	cs := consensus.NewConsensus(genesisTime, genesis.Producers, bc, config.Consensus{Interval: 1, MemCnt: len(genesis.Producers)}, clock.NewRealClock())
+	v := NewSnapshotVerifier(bc, cs, &version.Version{})
+
+	// every producer owns a slot of the first round, only one owns the slot of genesis time + 1s
+	slot1 := genesisTime.Add(time.Second)
+	passed := 0
+	for i := range genesis.Producers {
+		w := unlockedWallet(t, config.DevKey(i))
+		stat := v.VerifyReferred(genSnapshotBlockAt(bc, w.Sign, config.DevAddress(i).String(), slot1))
+		if stat.VerifyResult() == SUCCESS {
+			passed++
+		} else if stat.VerifyResult() != FAIL || stat.ErrMsg() == "" {
+			t.Error("block out of slot should fail.", i)
+		}
+	}
+	if passed != 1 {
+		t.Errorf("one producer should own the slot, but got %d", passed)
+	}
+	w7 := unlockedWallet(t, config.DevKey(7))
+	stat := v.VerifyReferred(genSnapshotBlockAt(bc, w7.Sign, config.DevAddress(7).String(), slot1))
+	if stat.VerifyResult() != FAIL || stat.ErrMsg() == "" {
+		t.Error("block of non-producer should fail.")
+	}
+	// the slot of genesis block
+	w0 := unlockedWallet(t, config.DevKey(0))
+	stat = v.VerifyReferred(genSnapshotBlockAt(bc, w0.Sign, config.DevAddress(0).String(), genesisTime))
+	if stat.VerifyResult() != FAIL {
+		t.Error("block not after previous block should fail.")
+	}
+}
+
+func TestSnapshotAccountsLimit(t *testing.T) {
+	bc := chain.NewChain("", config.DefaultGenesis())
+	v := NewSnapshotVerifier(bc, nil, &version.Version{})
+	v.maxAccounts = 2
+	w := unlockedWallet(t, config.DevKey(0))
+	producer := config.DevAddress(0).String()
+	genesis, _ := bc.GenesisSnapshot()
+
+	blockOf := func(accounts []*common.AccountHashH) *common.SnapshotBlock {
+		block := common.NewSnapshotBlock(1, "", genesis.Hash(), producer, time.Unix(genesis.Timestamp().Unix()+1, 0), accounts)
+		block.SetHash(tools.CalculateSnapshotHash(block))
+		tools.SignBlock(block, w.Sign)
+		return block
+	}
+	account := genesis.Accounts[0]
+	if stat := v.VerifyReferred(blockOf([]*common.AccountHashH{account, account})); stat.VerifyResult() != SUCCESS {
+		t.Error("block within limit should pass.", stat.ErrMsg())
+	}
+	if stat := v.VerifyReferred(blockOf([]*common.AccountHashH{account, account, account})); stat.VerifyResult() != FAIL {
+		t.Error("block exceeding limit should fail.")
+	}
+}
@@ -0,0 +1,85 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	mnemonicEntropyBits = 256
+	hardenedOffset      = 0x80000000
+	// m/44'/666666'/{index}'
+	accountPathPrefix = "m/44'/666666'/"
+)
+
+func accountPath(index int) string {
+	return accountPathPrefix + strconv.Itoa(index) + "'"
+}
+
+// index of account path, -1 if path is not an account path
+func pathIndex(path string) int {
+	if !strings.HasPrefix(path, accountPathPrefix) || !strings.HasSuffix(path, "'") {
+		return -1
+	}
+	index, err := strconv.Atoi(path[len(accountPathPrefix) : len(path)-1])
+	if err != nil || index < 0 {
+		return -1
+	}
+	return index
+}
+
+func newMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+func seedFromMnemonic(mnemonic string, password string) ([]byte, error) {
+	return bip39.NewSeedWithErrorChecking(mnemonic, password)
+}
+
+// SLIP-10 ed25519 key derivation, only hardened path is supported
+func deriveKey(seed []byte, path string) (ed25519.PrivateKey, error) {
+	segments := strings.Split(path, "/")
+	if len(segments) == 0 || segments[0] != "m" {
+		return nil, errors.New("path[" + path + "] must start with m")
+	}
+	key, chainCode := slip10Master(seed)
+	for _, s := range segments[1:] {
+		if !strings.HasSuffix(s, "'") {
+			return nil, errors.New("path[" + path + "] has non-hardened index, ed25519 only supports hardened")
+		}
+		i, err := strconv.ParseUint(s[:len(s)-1], 10, 32)
+		if err != nil || i >= hardenedOffset {
+			return nil, errors.New("path[" + path + "] index is invalid")
+		}
+		key, chainCode = slip10Child(key, chainCode, uint32(i)+hardenedOffset)
+	}
+	return ed25519.NewKeyFromSeed(key), nil
+}
+
+func slip10Master(seed []byte) ([]byte, []byte) {
+	h := hmac.New(sha512.New, []byte("ed25519 seed"))
+	h.Write(seed)
+	sum := h.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+func slip10Child(key []byte, chainCode []byte, index uint32) ([]byte, []byte) {
+	data := make([]byte, 37)
+	copy(data[1:33], key)
+	binary.BigEndian.PutUint32(data[33:], index)
+	h := hmac.New(sha512.New, chainCode)
+	h.Write(data)
+	sum := h.Sum(nil)
+	return sum[:32], sum[32:]
+}
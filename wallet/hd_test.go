@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+// test vector 1 for ed25519 of SLIP-0010
+func TestDeriveKey(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []struct {
+		path string
+		key  string
+		pub  string
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", "8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", "1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187"},
+		{"m/0'/1'/2'", "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9", "ae98736566d30ed0e9d2f4486a64bc95740d89c7db33f52121f8ea8f76ff0fc1"},
+	}
+	for _, v := range vectors {
+		key, err := deriveKey(seed, v.path)
+		if err != nil {
+			t.Fatal(v.path, err)
+		}
+		if hex.EncodeToString(key.Seed()) != v.key {
+			t.Error("private key error.", v.path, hex.EncodeToString(key.Seed()))
+		}
+		if hex.EncodeToString(key.Public().(ed25519.PublicKey)) != v.pub {
+			t.Error("public key error.", v.path, hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+		}
+	}
+
+	if _, err := deriveKey(seed, "m/0"); err == nil {
+		t.Error("non-hardened path should fail.")
+	}
+}
+
+// test vector of BIP-0039
+func TestSeedFromMnemonic(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	seed, err := seedFromMnemonic(mnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if hex.EncodeToString(seed) != expected {
+		t.Error("seed error.", hex.EncodeToString(seed))
+	}
+
+	if _, err := seedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", ""); err == nil {
+		t.Error("mnemonic with wrong checksum should fail.")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	w, _ := NewWallet("")
+	mnemonic, err := w.NewMnemonic("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.DeriveAccount(2, "wrong"); err == nil {
+		t.Error("derive with wrong passphrase should fail.")
+	}
+	derived, err := w.DeriveAccount(2, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _ := w.CreateAccount("123456")
+	first := w.Accounts()[0]
+
+	other, _ := NewWallet("")
+	recovered, err := other.Recover(mnemonic, "654321", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 3 || recovered[0] != first || recovered[2] != derived {
+		t.Fatalf("recovered accounts error. %v", recovered)
+	}
+	if err := other.Unlock(derived, "654321", 0); err != nil {
+		t.Error(err)
+	}
+
+	accounts := w.Accounts()
+	if len(accounts) != 3 || accounts[0] != first || accounts[1] != derived || accounts[2] != imported {
+		t.Errorf("accounts order error. %v", accounts)
+	}
+}
@@ -0,0 +1,371 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/viteshan/naive-vite/common"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyFileVersion = 1
+	keyFileSuffix  = ".json"
+	seedFileName   = "seed"
+
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	scryptDKLen = 32
+
+	// key files are read from disk or imported, bound the cost of deriving their keys
+	maxScryptN = 1 << 18
+	maxScryptR = 8
+	maxScryptP = 4
+)
+
+var errWrongPassphrase = errors.New("could not decrypt key with given passphrase")
+var errNoSeed = errors.New("wallet has no mnemonic seed")
+
+// encrypted key file, one file per account
+type keyFile struct {
+	Address string
+	Path    string `json:",omitempty"` // derivation path if derived from seed
+	Crypto  cryptoJson
+	Version int
+}
+
+// encrypted mnemonic of derived accounts
+type seedFile struct {
+	Crypto  cryptoJson
+	Version int
+}
+
+type cryptoJson struct {
+	Cipher     string // aes-256-gcm
+	CipherText string
+	Nonce      string
+	Kdf        string // scrypt
+	KdfParams  scryptParams
+}
+
+type scryptParams struct {
+	N     int
+	R     int
+	P     int
+	DKLen int
+	Salt  string
+}
+
+// keys are encrypted by passphrase, kept in dir or only in memory if dir is empty
+type keyStore struct {
+	dir  string
+	mem  map[string][]byte
+	seed []byte // seed file in memory
+	mu   sync.RWMutex
+}
+
+func newKeyStore(dir string) (*keyStore, error) {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &keyStore{dir: dir, mem: make(map[string][]byte)}, nil
+}
+
+func (self *keyStore) addresses() []string {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	var result []string
+	if self.dir == "" {
+		for k := range self.mem {
+			result = append(result, k)
+		}
+	} else {
+		files, _ := ioutil.ReadDir(self.dir)
+		for _, f := range files {
+			name := f.Name()
+			if f.IsDir() || !strings.HasSuffix(name, keyFileSuffix) {
+				continue
+			}
+			address := strings.TrimSuffix(name, keyFileSuffix)
+			if common.IsValidAddress(address) {
+				result = append(result, address)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (self *keyStore) has(address string) bool {
+	_, err := self.load(address)
+	return err == nil
+}
+
+// encrypted key file of address
+func (self *keyStore) load(address string) ([]byte, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	if self.dir == "" {
+		keyJson, ok := self.mem[address]
+		if !ok {
+			return nil, errors.New("account[" + address + "] not exist in wallet")
+		}
+		return keyJson, nil
+	}
+	keyJson, err := ioutil.ReadFile(self.keyPath(address))
+	if os.IsNotExist(err) {
+		return nil, errors.New("account[" + address + "] not exist in wallet")
+	}
+	return keyJson, err
+}
+
+func (self *keyStore) store(address string, keyJson []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.dir == "" {
+		self.mem[address] = keyJson
+		return nil
+	}
+	return writeFile(self.keyPath(address), keyJson)
+}
+
+// write to a temp file first, never leave a broken key file
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+	err := ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (self *keyStore) getKey(address string, passphrase string) (ed25519.PrivateKey, error) {
+	keyJson, err := self.load(address)
+	if err != nil {
+		return nil, err
+	}
+	addr, key, err := decryptKey(keyJson, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if addr != address {
+		return nil, errors.New("key file of account[" + address + "] contains address[" + addr + "]")
+	}
+	return key, nil
+}
+
+// derivation path of address, empty if it's not derived
+func (self *keyStore) path(address string) string {
+	keyJson, err := self.load(address)
+	if err != nil {
+		return ""
+	}
+	k, err := readKeyFile(keyJson)
+	if err != nil {
+		return ""
+	}
+	return k.Path
+}
+
+func (self *keyStore) hasSeed() bool {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	if self.dir == "" {
+		return self.seed != nil
+	}
+	_, err := os.Stat(filepath.Join(self.dir, seedFileName))
+	return err == nil
+}
+
+func (self *keyStore) loadSeed(passphrase string) (string, error) {
+	self.mu.RLock()
+	seedJson := self.seed
+	self.mu.RUnlock()
+	if self.dir != "" {
+		var err error
+		seedJson, err = ioutil.ReadFile(filepath.Join(self.dir, seedFileName))
+		if os.IsNotExist(err) {
+			return "", errNoSeed
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if seedJson == nil {
+		return "", errNoSeed
+	}
+	f := &seedFile{}
+	err := json.Unmarshal(seedJson, f)
+	if err != nil {
+		return "", err
+	}
+	if f.Version != keyFileVersion {
+		return "", errors.New("seed file version is not supported")
+	}
+	mnemonic, err := decryptData(&f.Crypto, passphrase)
+	if err != nil {
+		return "", err
+	}
+	return string(mnemonic), nil
+}
+
+func (self *keyStore) storeSeed(mnemonic string, passphrase string) error {
+	c, err := encryptData([]byte(mnemonic), passphrase)
+	if err != nil {
+		return err
+	}
+	seedJson, err := json.Marshal(&seedFile{Crypto: *c, Version: keyFileVersion})
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.dir == "" {
+		self.seed = seedJson
+		return nil
+	}
+	return writeFile(filepath.Join(self.dir, seedFileName), seedJson)
+}
+
+func (self *keyStore) keyPath(address string) string {
+	return filepath.Join(self.dir, address+keyFileSuffix)
+}
+
+func encryptKey(key ed25519.PrivateKey, path string, passphrase string) ([]byte, error) {
+	// only seed is encrypted, the private key is derived from it
+	c, err := encryptData(key.Seed(), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	address := common.PubKeyToAddress(key.Public().(ed25519.PublicKey)).String()
+	return json.Marshal(&keyFile{Address: address, Path: path, Crypto: *c, Version: keyFileVersion})
+}
+
+func decryptKey(keyJson []byte, passphrase string) (string, ed25519.PrivateKey, error) {
+	k, err := readKeyFile(keyJson)
+	if err != nil {
+		return "", nil, err
+	}
+	seed, err := decryptData(&k.Crypto, passphrase)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return "", nil, errors.New("key file seed is invalid")
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	address := common.PubKeyToAddress(key.Public().(ed25519.PublicKey)).String()
+	if address != k.Address {
+		return "", nil, errors.New("key file address[" + k.Address + "] does not match key")
+	}
+	return address, key, nil
+}
+
+// read the plain part of key file
+func readKeyFile(keyJson []byte) (*keyFile, error) {
+	k := &keyFile{}
+	err := json.Unmarshal(keyJson, k)
+	if err != nil {
+		return nil, err
+	}
+	if k.Version != keyFileVersion {
+		return nil, errors.New("key file version is not supported")
+	}
+	return k, nil
+}
+
+func encryptData(data []byte, passphrase string) (*cryptoJson, error) {
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(derived)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptoJson{
+		Cipher:     "aes-256-gcm",
+		CipherText: hex.EncodeToString(gcm.Seal(nil, nonce, data, nil)),
+		Nonce:      hex.EncodeToString(nonce),
+		Kdf:        "scrypt",
+		KdfParams: scryptParams{
+			N:     scryptN,
+			R:     scryptR,
+			P:     scryptP,
+			DKLen: scryptDKLen,
+			Salt:  hex.EncodeToString(salt),
+		},
+	}, nil
+}
+
+func decryptData(c *cryptoJson, passphrase string) ([]byte, error) {
+	if c.Cipher != "aes-256-gcm" || c.Kdf != "scrypt" {
+		return nil, errors.New("cipher is not supported")
+	}
+	params := c.KdfParams
+	if params.N > maxScryptN || params.R < 1 || params.R > maxScryptR || params.P < 1 || params.P > maxScryptP ||
+		params.DKLen != scryptDKLen {
+		return nil, errors.New("kdf params are out of range")
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(derived)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("nonce is invalid")
+	}
+	data, err := gcm.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,38 +1,98 @@
 package wallet
 
-import "sort"
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+)
 
 type Wallet interface {
+	// derived accounts ordered by index, then other accounts ordered by address
 	Accounts() []string
-	CreateAccount(address string) string
-	SetCoinBase(string)
+	// generate a new key encrypted by passphrase, return its address
+	CreateAccount(passphrase string) (string, error)
+	// import a raw private key, encrypted by passphrase
+	ImportKey(key ed25519.PrivateKey, passphrase string) (string, error)
+	// import an encrypted key file exported by Export
+	Import(keyJson []byte, passphrase string) (string, error)
+	// export the encrypted key file of address
+	Export(address string, passphrase string) ([]byte, error)
+
+	// generate a mnemonic seed and derive account 0 from it. mnemonic should be backed up.
+	NewMnemonic(passphrase string) (string, error)
+	// restore seed from mnemonic and derive account 0 to count-1
+	Recover(mnemonic string, passphrase string, count int) ([]string, error)
+	// derive account of index from the seed of wallet
+	DeriveAccount(index int, passphrase string) (string, error)
+
+	// duration 0 means unlocked until Lock
+	Unlock(address string, passphrase string, duration time.Duration) error
+	Lock(address string) error
+
+	// address must be an account of wallet
+	SetCoinBase(address string) error
 	CoinBase() string
+	// sign data by the key of address, return signature and public key. address must be unlocked.
+	Sign(a common.Address, data []byte) ([]byte, []byte, error)
 }
 
-func NewWallet() Wallet {
-	w := &wallet{}
-	w.accounts = make(map[string]string)
-	return w
+// if dir is empty, keys are only kept in memory
+func NewWallet(dir string) (Wallet, error) {
+	ks, err := newKeyStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{ks: ks}
+	w.unlocked = make(map[string]*unlocked)
+	return w, nil
 }
 
 type wallet struct {
-	accounts map[string]string
+	ks       *keyStore
+	unlocked map[string]*unlocked
 	current  string
+	mu       sync.Mutex
+}
+
+type unlocked struct {
+	key   ed25519.PrivateKey
+	timer *time.Timer
 }
 
 func (self *wallet) Accounts() []string {
-	var accs []string
-	for k, _ := range self.accounts {
-		accs = append(accs, k)
+	addresses := self.ks.addresses()
+	indexes := make(map[string]int)
+	for _, a := range addresses {
+		indexes[a] = pathIndex(self.ks.path(a))
 	}
-	sort.Strings(accs)
-	return accs
+	// addresses are already sorted
+	sort.SliceStable(addresses, func(i, j int) bool {
+		a, b := indexes[addresses[i]], indexes[addresses[j]]
+		if a < 0 || b < 0 {
+			return a >= 0 && b < 0
+		}
+		return a < b
+	})
+	return addresses
 }
-func (self *wallet) SetCoinBase(address string) {
-	self.accounts[address] = address
+func (self *wallet) SetCoinBase(address string) error {
+	if !self.ks.has(address) {
+		return errors.New("account[" + address + "] not exist in wallet")
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
 	self.current = address
+	return nil
 }
 func (self *wallet) CoinBase() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
 	if self.current == "" {
 		accs := self.Accounts()
 		if len(accs) > 0 {
@@ -41,11 +101,168 @@ func (self *wallet) CoinBase() string {
 	}
 	return self.current
 }
-func (self *wallet) CreateAccount(address string) string {
-	self.accounts[address] = address
-	return address
+
+func (self *wallet) CreateAccount(passphrase string) (string, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return self.ImportKey(priv, passphrase)
 }
 
-func (self *wallet) Sign(address string, data []byte) []byte {
-	return data
+func (self *wallet) ImportKey(key ed25519.PrivateKey, passphrase string) (string, error) {
+	address := common.PubKeyToAddress(key.Public().(ed25519.PublicKey)).String()
+	if self.ks.has(address) {
+		return "", errors.New("account[" + address + "] already exists in wallet")
+	}
+	return self.storeKey(key, "", passphrase)
+}
+
+func (self *wallet) storeKey(key ed25519.PrivateKey, path string, passphrase string) (string, error) {
+	keyJson, err := encryptKey(key, path, passphrase)
+	if err != nil {
+		return "", err
+	}
+	address := common.PubKeyToAddress(key.Public().(ed25519.PublicKey)).String()
+	err = self.ks.store(address, keyJson)
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+func (self *wallet) Import(keyJson []byte, passphrase string) (string, error) {
+	address, _, err := decryptKey(keyJson, passphrase)
+	if err != nil {
+		return "", err
+	}
+	if self.ks.has(address) {
+		return "", errors.New("account[" + address + "] already exists in wallet")
+	}
+	err = self.ks.store(address, keyJson)
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+func (self *wallet) Export(address string, passphrase string) ([]byte, error) {
+	// passphrase is checked, the exported file must be importable
+	_, err := self.ks.getKey(address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return self.ks.load(address)
+}
+
+func (self *wallet) NewMnemonic(passphrase string) (string, error) {
+	mnemonic, err := newMnemonic()
+	if err != nil {
+		return "", err
+	}
+	_, err = self.Recover(mnemonic, passphrase, 1)
+	if err != nil {
+		return "", err
+	}
+	return mnemonic, nil
+}
+
+func (self *wallet) Recover(mnemonic string, passphrase string, count int) ([]string, error) {
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	if self.ks.hasSeed() {
+		return nil, errors.New("wallet already has a mnemonic seed")
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := seedFromMnemonic(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	err = self.ks.storeSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for i := 0; i < count; i++ {
+		address, err := self.deriveAccount(seed, i, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, address)
+	}
+	return result, nil
+}
+
+func (self *wallet) DeriveAccount(index int, passphrase string) (string, error) {
+	if index < 0 {
+		return "", errors.New("index must not be negative")
+	}
+	mnemonic, err := self.ks.loadSeed(passphrase)
+	if err != nil {
+		return "", err
+	}
+	seed, err := seedFromMnemonic(mnemonic, "")
+	if err != nil {
+		return "", err
+	}
+	return self.deriveAccount(seed, index, passphrase)
+}
+
+func (self *wallet) deriveAccount(seed []byte, index int, passphrase string) (string, error) {
+	key, err := deriveKey(seed, accountPath(index))
+	if err != nil {
+		return "", err
+	}
+	return self.storeKey(key, accountPath(index), passphrase)
+}
+
+func (self *wallet) Unlock(address string, passphrase string, duration time.Duration) error {
+	key, err := self.ks.getKey(address, passphrase)
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if u, ok := self.unlocked[address]; ok && u.timer != nil {
+		u.timer.Stop()
+	}
+	u := &unlocked{key: key}
+	if duration > 0 {
+		u.timer = time.AfterFunc(duration, func() {
+			self.mu.Lock()
+			defer self.mu.Unlock()
+			// relocked by a later Unlock
+			if self.unlocked[address] == u {
+				delete(self.unlocked, address)
+			}
+		})
+	}
+	self.unlocked[address] = u
+	return nil
+}
+
+func (self *wallet) Lock(address string) error {
+	if !self.ks.has(address) {
+		return errors.New("account[" + address + "] not exist in wallet")
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if u, ok := self.unlocked[address]; ok {
+		if u.timer != nil {
+			u.timer.Stop()
+		}
+		delete(self.unlocked, address)
+	}
+	return nil
+}
+
+func (self *wallet) Sign(a common.Address, data []byte) ([]byte, []byte, error) {
+	self.mu.Lock()
+	u, ok := self.unlocked[a.String()]
+	self.mu.Unlock()
+	if !ok {
+		return nil, nil, errors.New("account[" + a.String() + "] is locked")
+	}
+	return ed25519.Sign(u.key, data), []byte(u.key.Public().(ed25519.PublicKey)), nil
 }
@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/viteshan/naive-vite/common"
+)
+
+func TestKeyStorePersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "naive-vite-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := w.CreateAccount("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !common.IsValidAddress(address) {
+		t.Fatal("address is invalid.", address)
+	}
+
+	// reopen
+	w, err = NewWallet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := w.Accounts()
+	if len(accounts) != 1 || accounts[0] != address {
+		t.Fatalf("accounts not reloaded. %v", accounts)
+	}
+	if err := w.Unlock(address, "wrong", 0); err == nil {
+		t.Error("unlock with wrong passphrase should fail.")
+	}
+	if err := w.Unlock(address, "123456", 0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignLocked(t *testing.T) {
+	w, _ := NewWallet("")
+	address, _ := w.CreateAccount("123456")
+	addr, _ := common.ParseAddress(address)
+
+	if _, _, err := w.Sign(addr, []byte("data")); err == nil {
+		t.Error("sign by locked account should fail.")
+	}
+	w.Unlock(address, "123456", 0)
+	if _, _, err := w.Sign(addr, []byte("data")); err != nil {
+		t.Error(err)
+	}
+	w.Lock(address)
+	if _, _, err := w.Sign(addr, []byte("data")); err == nil {
+		t.Error("sign by relocked account should fail.")
+	}
+
+	w.Unlock(address, "123456", 50*time.Millisecond)
+	if _, _, err := w.Sign(addr, []byte("data")); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := w.Sign(addr, []byte("data")); err == nil {
+		t.Error("sign after unlock duration should fail.")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	w, _ := NewWallet("")
+	address, _ := w.CreateAccount("123456")
+	if _, err := w.Export(address, "wrong"); err == nil {
+		t.Error("export with wrong passphrase should fail.")
+	}
+	keyJson, err := w.Export(address, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := NewWallet("")
+	if _, err := other.Import(keyJson, "wrong"); err == nil {
+		t.Error("import with wrong passphrase should fail.")
+	}
+	imported, err := other.Import(keyJson, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != address {
+		t.Error("imported address error.", imported, address)
+	}
+	if err := other.Unlock(address, "123456", 0); err != nil {
+		t.Error(err)
+	}
+	if _, err := other.Import(keyJson, "123456"); err == nil {
+		t.Error("import of existing account should fail.")
+	}
+	key, _ := other.(*wallet).ks.getKey(address, "123456")
+	if _, err := other.ImportKey(key, "654321"); err == nil {
+		t.Error("import of existing key should fail.")
+	}
+
+	k, _ := readKeyFile(keyJson)
+	k.Crypto.KdfParams.N = 1 << 30
+	keyJson, _ = json.Marshal(k)
+	fresh, _ := NewWallet("")
+	if _, err := fresh.Import(keyJson, "123456"); err == nil {
+		t.Error("key file with expensive kdf should be refused.")
+	}
+}